@@ -6,7 +6,7 @@ set -x
 if [[ -z $BUILD_DOCKER ]]; then
    go get ./...
    mkdir -p build
-   go build -o build/koinos_p2p cmd/koinos-p2p/main.go
+   go build -tags quic -o build/koinos_p2p cmd/koinos-p2p/main.go
 else
    TAG="$TRAVIS_BRANCH"
    if [ "$TAG" = "master" ]; then
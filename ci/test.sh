@@ -4,9 +4,9 @@ set -e
 set -x
 
 if [[ -z $BUILD_DOCKER ]]; then
-   go test -v github.com/koinos/koinos-p2p/internal -coverprofile=./build/internal.out -coverpkg=./internal/...
-   go test -v github.com/koinos/koinos-p2p/internal/node -coverprofile=./build/node.out -coverpkg=./internal/...
-   go test -v github.com/koinos/koinos-p2p/internal/p2p -coverprofile=./build/p2p.out -coverpkg=./internal/...
+   go test -v -tags quic github.com/koinos/koinos-p2p/internal -coverprofile=./build/internal.out -coverpkg=./internal/...
+   go test -v -tags quic github.com/koinos/koinos-p2p/internal/node -coverprofile=./build/node.out -coverpkg=./internal/...
+   go test -v -tags quic github.com/koinos/koinos-p2p/internal/p2p -coverprofile=./build/p2p.out -coverpkg=./internal/...
 
    gcov2lcov -infile=./build/internal.out -outfile=./build/internal.info
    gcov2lcov -infile=./build/node.out -outfile=./build/node.info
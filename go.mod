@@ -3,18 +3,25 @@ module github.com/koinos/koinos-p2p
 go 1.15
 
 require (
+	github.com/btcsuite/btcd v0.22.0-beta
 	github.com/ipfs/go-log v1.0.5
 	github.com/koinos/koinos-log-golang v0.0.0-20210621202301-3310a8e5866b
 	github.com/koinos/koinos-mq-golang v0.0.0-20210424202816-d2bd4d1894d1
 	github.com/koinos/koinos-proto-golang v0.0.0-20210914170258-3625b3f80c90
 	github.com/koinos/koinos-util-golang v0.0.0-20211019222021-3b7f67a3119d
 	github.com/libp2p/go-libp2p v0.15.1
+	github.com/libp2p/go-libp2p-circuit v0.4.0
 	github.com/libp2p/go-libp2p-core v0.9.0
 	github.com/libp2p/go-libp2p-gorpc v0.1.3
 	github.com/libp2p/go-libp2p-kad-dht v0.15.0
+	github.com/libp2p/go-libp2p-peerstore v0.2.8
 	github.com/libp2p/go-libp2p-pubsub v0.5.6
+	github.com/libp2p/go-libp2p-quic-transport v0.11.2
+	github.com/libp2p/go-tcp-transport v0.2.8
+	github.com/libp2p/go-ws-transport v0.5.0
 	github.com/multiformats/go-multiaddr v0.4.0
 	github.com/multiformats/go-multihash v0.0.15
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/pflag v1.0.5
 	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,230 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2p"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	// AdminRPCType is the rpc type on which the admin rpc is served
+	AdminRPCType = "p2p"
+
+	adminRPCTimeout = time.Second * 5
+)
+
+// AdminRequest is a JSON encoded admin rpc request
+type AdminRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// AdminResponse is a JSON encoded admin rpc response
+type AdminResponse struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// GetPeerErrorsParams are the params of get_peer_errors.
+// If PeerID is empty, the errors of all peers are returned.
+type GetPeerErrorsParams struct {
+	PeerID string `json:"peer_id,omitempty"`
+}
+
+// BanParams are the params of ban. Target is a peer ID, IP address, or CIDR range.
+// An empty duration bans the target permanently.
+type BanParams struct {
+	Target   string `json:"target"`
+	Duration string `json:"duration,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// UnbanParams are the params of unban
+type UnbanParams struct {
+	Target string `json:"target"`
+}
+
+// BanInfo describes a ban returned by get_bans
+type BanInfo struct {
+	Target     string     `json:"target"`
+	Expiration *time.Time `json:"expiration,omitempty"` // Omitted for a permanent ban
+	Reason     string     `json:"reason,omitempty"`
+	Offenses   uint64     `json:"offenses,omitempty"`
+}
+
+// PeerInfo describes a connected peer returned by get_peers
+type PeerInfo struct {
+	PeerID     string  `json:"peer_id"`
+	Handshaked bool    `json:"handshaked"`
+	Synced     bool    `json:"synced"`
+	HeadHeight uint64  `json:"head_height"`
+	RTT        float64 `json:"rtt_ms,omitempty"` // Omitted until the peer has responded to a ping
+}
+
+type adminMethod func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+func (n *KoinosP2PNode) adminMethods() map[string]adminMethod {
+	return map[string]adminMethod{
+		"get_peers":       n.adminGetPeers,
+		"get_peer_errors": n.adminGetPeerErrors,
+		"get_bans":        n.adminGetBans,
+		"ban":             n.adminBan,
+		"unban":           n.adminUnban,
+	}
+}
+
+func (n *KoinosP2PNode) handleAdminRPC(rpcType string, data []byte) ([]byte, error) {
+	log.Debugf("Received admin rpc: %s", string(data))
+
+	response := AdminResponse{}
+	result, err := n.handleAdminRequest(data)
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Result = result
+	}
+
+	return json.Marshal(response)
+}
+
+func (n *KoinosP2PNode) handleAdminRequest(data []byte) (interface{}, error) {
+	request := AdminRequest{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("could not parse request: %w", err)
+	}
+
+	method, ok := n.adminMethods()[request.Method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method '%s'", request.Method)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminRPCTimeout)
+	defer cancel()
+
+	return method(ctx, request.Params)
+}
+
+func parseAdminParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("could not parse params: %w", err)
+	}
+
+	return nil
+}
+
+func (n *KoinosP2PNode) adminGetPeers(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	statuses := n.ConnectionManager.GetPeerStatus(ctx)
+	if statuses == nil {
+		return nil, ctx.Err()
+	}
+
+	result := make([]PeerInfo, 0, len(statuses))
+	for _, status := range statuses {
+		result = append(result, PeerInfo{
+			PeerID:     status.ID.Pretty(),
+			Handshaked: status.Handshaked,
+			Synced:     status.Synced,
+			HeadHeight: status.HeadHeight,
+			RTT:        float64(status.RTT) / float64(time.Millisecond),
+		})
+	}
+
+	return result, nil
+}
+
+func (n *KoinosP2PNode) adminGetPeerErrors(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	p := GetPeerErrorsParams{}
+	if err := parseAdminParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	var id peer.ID
+	if p.PeerID != "" {
+		var err error
+		id, err = peer.Decode(p.PeerID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer id '%s': %w", p.PeerID, err)
+		}
+	}
+
+	histories := n.PeerErrorHandler.GetErrorHistories(ctx, id)
+	if histories == nil {
+		return nil, ctx.Err()
+	}
+
+	// Key by the encoded peer ID rather than the raw bytes
+	result := make(map[string]interface{}, len(histories))
+	for pid, history := range histories {
+		result[pid.Pretty()] = history
+	}
+
+	return result, nil
+}
+
+func (n *KoinosP2PNode) adminGetBans(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	bans := n.PeerErrorHandler.AccessList.Bans()
+
+	result := make([]BanInfo, 0, len(bans))
+	for _, ban := range bans {
+		info := BanInfo{
+			Target:   ban.Target(),
+			Reason:   ban.Reason,
+			Offenses: ban.Offenses,
+		}
+		if !ban.Expiration.IsZero() {
+			expiration := ban.Expiration
+			info.Expiration = &expiration
+		}
+		result = append(result, info)
+	}
+
+	return result, nil
+}
+
+func (n *KoinosP2PNode) adminBan(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	p := BanParams{}
+	if err := parseAdminParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	entry := options.BanEntry{Target: p.Target, Reason: p.Reason}
+	if p.Duration != "" {
+		duration, err := time.ParseDuration(p.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration '%s': %w", p.Duration, err)
+		}
+		entry.Expiration = time.Now().Add(duration)
+	}
+
+	ban, err := p2p.ParseBan(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if ban.Subnet != nil {
+		n.PeerErrorHandler.AccessList.AddBan(ban)
+	} else {
+		n.PeerErrorHandler.BanPeer(ctx, ban.Peer, ban.Expiration, ban.Reason)
+	}
+
+	return true, nil
+}
+
+func (n *KoinosP2PNode) adminUnban(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	p := UnbanParams{}
+	if err := parseAdminParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return n.PeerErrorHandler.AccessList.RemoveBan(p.Target), nil
+}
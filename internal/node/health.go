@@ -0,0 +1,146 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HealthPath is the HTTP path of the health endpoint
+const HealthPath = "/health"
+
+// HealthStatus reports whether the node is connected and useful to the network
+type HealthStatus struct {
+	Healthy                bool     `json:"healthy"`
+	Degraded               bool     `json:"degraded"` // Sync and gossip are paused until the local services are available
+	Problems               []string `json:"problems,omitempty"`
+	ConnectedToChain       bool     `json:"connected_to_chain"`
+	ConnectedToBlockStore  bool     `json:"connected_to_block_store"`
+	Peers                  int      `json:"peers"`
+	HandshakedPeers        int      `json:"handshaked_peers"`
+	GossipEnabled          bool     `json:"gossip_enabled"`
+	LastIrreversibleHeight uint64   `json:"last_irreversible_height"`
+	HeadHeight             uint64   `json:"head_height"`
+	BestPeerHeadHeight     uint64   `json:"best_peer_head_height"`
+}
+
+// headProgress tracks when the local head last advanced to detect a stalled node
+type headProgress struct {
+	height     uint64
+	lastChange time.Time
+	mutex      sync.Mutex
+}
+
+// update records the head height, returning how long the head has been at this height
+func (h *headProgress) update(height uint64) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	if height != h.height || h.lastChange.IsZero() {
+		h.height = height
+		h.lastChange = now
+	}
+
+	return now.Sub(h.lastChange)
+}
+
+// GetHealth checks the local services and peers of the node
+func (n *KoinosP2PNode) GetHealth(ctx context.Context) *HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, n.healthOpts.CheckTimeout)
+	defer cancel()
+
+	status := &HealthStatus{
+		Degraded:               !n.LocalServicesAvailable(),
+		GossipEnabled:          n.Gossip.IsGossipEnabled(),
+		LastIrreversibleHeight: n.GetLastIrreversibleBlock().Height,
+	}
+
+	status.ConnectedToChain, _ = n.localRPC.IsConnectedToChain(ctx)
+	status.ConnectedToBlockStore, _ = n.localRPC.IsConnectedToBlockStore(ctx)
+
+	if !status.ConnectedToChain {
+		status.Problems = append(status.Problems, "not connected to chain")
+	}
+	if !status.ConnectedToBlockStore {
+		status.Problems = append(status.Problems, "not connected to block store")
+	}
+	if status.Degraded {
+		status.Problems = append(status.Problems, "sync and gossip paused")
+	}
+
+	if headInfo, err := n.localRPC.GetHeadBlock(ctx); err == nil && headInfo.HeadTopology != nil {
+		status.HeadHeight = headInfo.HeadTopology.Height
+	}
+
+	for _, peerStatus := range n.ConnectionManager.GetPeerStatus(ctx) {
+		status.Peers++
+		if peerStatus.Handshaked {
+			status.HandshakedPeers++
+		}
+		if peerStatus.HeadHeight > status.BestPeerHeadHeight {
+			status.BestPeerHeadHeight = peerStatus.HeadHeight
+		}
+	}
+
+	// Only a lack of usable peers or a stalled sync makes the node unhealthy
+	status.Healthy = true
+
+	if status.HandshakedPeers == 0 {
+		status.Healthy = false
+		status.Problems = append(status.Problems, "no usable peers")
+	}
+
+	stalledFor := n.headProgress.update(status.HeadHeight)
+	if status.BestPeerHeadHeight > status.HeadHeight && stalledFor >= n.healthOpts.StallDuration {
+		status.Healthy = false
+		status.Problems = append(status.Problems, "head has not advanced for "+stalledFor.Round(time.Second).String())
+	}
+
+	return status
+}
+
+func (n *KoinosP2PNode) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := n.GetHealth(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Warnf("Error writing health response: %s", err)
+	}
+}
+
+func (n *KoinosP2PNode) startHealthServer(ctx context.Context) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newMetricsCollector(n))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, n.handleHealth)
+	mux.Handle(MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:    n.healthOpts.ListenAddress,
+		Handler: mux,
+	}
+
+	go func() {
+		log.Infof("Serving health and metrics endpoints at %s%s and %s", n.healthOpts.ListenAddress, HealthPath, MetricsPath)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Error serving health and metrics endpoints: %s", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+}
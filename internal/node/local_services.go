@@ -0,0 +1,138 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+)
+
+const localServiceMinBackoff = time.Second
+
+// LocalServicesAvailable returns if the chain and block store are reachable.
+// Sync and gossip are paused while they are not.
+func (n *KoinosP2PNode) LocalServicesAvailable() bool {
+	return atomic.LoadInt32(&n.localServicesAvailable) == 1
+}
+
+// checkLocalServices checks if the chain and block store are reachable and reloads the last irreversible block.
+// The chain is considered restarted if its chain ID changed, its head moved backwards, or it is behind the
+// last irreversible block.
+func (n *KoinosP2PNode) checkLocalServices(ctx context.Context) (available bool, restarted bool) {
+	ctx, cancel := context.WithTimeout(ctx, n.Options.LocalServiceCheckTimeout)
+	defer cancel()
+
+	if connected, err := n.localRPC.IsConnectedToChain(ctx); err != nil || !connected {
+		return false, false
+	}
+
+	if connected, err := n.localRPC.IsConnectedToBlockStore(ctx); err != nil || !connected {
+		return false, false
+	}
+
+	chainID, err := n.localRPC.GetChainID(ctx)
+	if err != nil {
+		return false, false
+	}
+
+	headInfo, err := n.localRPC.GetHeadBlock(ctx)
+	if err != nil || headInfo.HeadTopology == nil {
+		return false, false
+	}
+
+	forkHeads, err := n.localRPC.GetForkHeads(ctx)
+	if err != nil || forkHeads.LastIrreversibleBlock == nil {
+		return false, false
+	}
+
+	// The last irreversible block can never move backwards unless the chain was reset
+	lib := n.GetLastIrreversibleBlock()
+	switch {
+	case n.localChainID != nil && !bytes.Equal(n.localChainID, chainID.ChainId):
+		log.Warnf("Chain ID changed from %s to %s", hex.EncodeToString(n.localChainID), hex.EncodeToString(chainID.ChainId))
+		restarted = true
+	case headInfo.HeadTopology.Height < lib.Height || forkHeads.LastIrreversibleBlock.Height < lib.Height:
+		log.Warnf("Chain moved behind last irreversible block %v", lib.Height)
+		restarted = true
+	case headInfo.HeadTopology.Height < n.localHeadHeight:
+		log.Warnf("Chain head moved backwards from %v to %v", n.localHeadHeight, headInfo.HeadTopology.Height)
+		restarted = true
+	}
+
+	n.localChainID = chainID.ChainId
+	n.localHeadHeight = headInfo.HeadTopology.Height
+	n.libValue.Store(forkHeads.LastIrreversibleBlock)
+
+	return true, restarted
+}
+
+// handleChainRestart restarts sync with all peers after the chain restarted with different state
+func (n *KoinosP2PNode) handleChainRestart(ctx context.Context) {
+	log.Warn("Chain restart detected, restarting sync with all peers")
+	n.ConnectionManager.RestartSync(ctx)
+
+	// Peers vote on gossip again after their handshake, but the restart may have cleared the mempool
+	n.gossipMutex.Lock()
+	defer n.gossipMutex.Unlock()
+	if n.gossipEnabled {
+		go n.ConnectionManager.SyncPendingTransactions(ctx)
+	}
+}
+
+func (n *KoinosP2PNode) setLocalServicesAvailable(ctx context.Context, available bool) {
+	if available == n.LocalServicesAvailable() {
+		return
+	}
+
+	if available {
+		atomic.StoreInt32(&n.localServicesAvailable, 1)
+		log.Info("Connected to chain and block store, resuming sync and gossip")
+		n.ConnectionManager.ResumeSync(ctx)
+	} else {
+		atomic.StoreInt32(&n.localServicesAvailable, 0)
+		log.Warn("Lost connection to chain or block store, pausing sync and gossip")
+		n.ConnectionManager.PauseSync(ctx)
+	}
+
+	n.gossipMutex.Lock()
+	defer n.gossipMutex.Unlock()
+	n.updateGossip(ctx)
+}
+
+// monitorLocalServices pauses and resumes sync and gossip as the chain and block store become unreachable and reachable
+func (n *KoinosP2PNode) monitorLocalServices(ctx context.Context) {
+	minBackoff := localServiceMinBackoff
+	if minBackoff > n.Options.LocalServiceMaxBackoff {
+		minBackoff = n.Options.LocalServiceMaxBackoff
+	}
+	backoff := minBackoff
+
+	for {
+		available, restarted := n.checkLocalServices(ctx)
+		if restarted && n.LocalServicesAvailable() {
+			n.handleChainRestart(ctx)
+		}
+		n.setLocalServicesAvailable(ctx, available)
+
+		wait := n.Options.LocalServiceCheckInterval
+		if available {
+			backoff = minBackoff
+		} else {
+			log.Debugf("Chain or block store unavailable, checking again in %s", backoff)
+			wait = backoff
+			backoff *= 2
+			if backoff > n.Options.LocalServiceMaxBackoff {
+				backoff = n.Options.LocalServiceMaxBackoff
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsPath is the HTTP path of the Prometheus metrics endpoint
+const MetricsPath = "/metrics"
+
+const metricsNamespace = "koinos_p2p"
+
+// metricsCollector collects the node's metrics when they are scraped
+type metricsCollector struct {
+	node *KoinosP2PNode
+
+	peers   *prometheus.Desc
+	peerRTT *prometheus.Desc
+}
+
+func newMetricsCollector(node *KoinosP2PNode) *metricsCollector {
+	return &metricsCollector{
+		node:    node,
+		peers:   prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "peers"), "Number of connected peers", nil, nil),
+		peerRTT: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "peer", "rtt_seconds"), "Smoothed round trip time to the peer", []string{"peer_id"}, nil),
+	}
+}
+
+// Describe is part of the prometheus.Collector interface
+func (m *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.peers
+	ch <- m.peerRTT
+}
+
+// Collect is part of the prometheus.Collector interface
+func (m *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.node.healthOpts.CheckTimeout)
+	defer cancel()
+
+	statuses := m.node.ConnectionManager.GetPeerStatus(ctx)
+	ch <- prometheus.MustNewConstMetric(m.peers, prometheus.GaugeValue, float64(len(statuses)))
+
+	for _, status := range statuses {
+		if status.RTT > 0 {
+			ch <- prometheus.MustNewConstMetric(m.peerRTT, prometheus.GaugeValue, status.RTT.Seconds(), status.ID.Pretty())
+		}
+	}
+}
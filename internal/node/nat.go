@@ -0,0 +1,107 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	"github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// ErrIncompatibleOptions is when node options that cannot be used together are set
+var ErrIncompatibleOptions = errors.New("incompatible node options")
+
+// natOptions returns the libp2p options configuring NAT traversal, relaying, and AutoNAT
+func natOptions(opts *options.NodeOptions) ([]libp2p.Option, error) {
+	natOpts := make([]libp2p.Option, 0)
+
+	if opts.EnableNATPortMap {
+		natOpts = append(natOpts, libp2p.NATPortMap())
+	}
+
+	// Peers other than its sentries cannot connect to a node in sentry mode
+	if opts.SentryMode && opts.EnableRelayHop {
+		return nil, fmt.Errorf("%w, a node in sentry mode cannot relay for other peers", ErrIncompatibleOptions)
+	}
+
+	if opts.EnableRelay {
+		if opts.EnableRelayHop {
+			natOpts = append(natOpts, libp2p.EnableRelay(circuit.OptHop))
+		} else {
+			natOpts = append(natOpts, libp2p.EnableRelay())
+		}
+	} else {
+		if opts.EnableAutoRelay {
+			return nil, fmt.Errorf("%w, auto relay requires relay", ErrIncompatibleOptions)
+		}
+		if opts.EnableRelayHop {
+			return nil, fmt.Errorf("%w, relay hop requires relay", ErrIncompatibleOptions)
+		}
+		natOpts = append(natOpts, libp2p.DisableRelay())
+	}
+
+	if len(opts.StaticRelays) > 0 {
+		if !opts.EnableAutoRelay {
+			return nil, fmt.Errorf("%w, static relays require auto relay", ErrIncompatibleOptions)
+		}
+		// A relay hop advertises itself instead of using other relays
+		if opts.EnableRelayHop {
+			return nil, fmt.Errorf("%w, a relay hop does not use static relays", ErrIncompatibleOptions)
+		}
+
+		relays, err := parseStaticRelays(opts.StaticRelays)
+		if err != nil {
+			return nil, err
+		}
+		natOpts = append(natOpts, libp2p.StaticRelays(relays))
+	}
+
+	if opts.EnableAutoRelay {
+		natOpts = append(natOpts, libp2p.EnableAutoRelay())
+	}
+
+	if opts.EnableNATService {
+		natOpts = append(natOpts, libp2p.EnableNATService())
+	}
+
+	switch opts.ForceReachability {
+	case "":
+	case options.ReachabilityPublic:
+		// Auto relay only looks for relays once the node is found to be unreachable
+		if opts.EnableAutoRelay && !opts.EnableRelayHop {
+			return nil, fmt.Errorf("%w, auto relay is never used by a node forced to be publicly reachable", ErrIncompatibleOptions)
+		}
+		natOpts = append(natOpts, libp2p.ForceReachabilityPublic())
+	case options.ReachabilityPrivate:
+		if opts.EnableRelayHop {
+			return nil, fmt.Errorf("%w, a relay hop must be publicly reachable", ErrIncompatibleOptions)
+		}
+		natOpts = append(natOpts, libp2p.ForceReachabilityPrivate())
+	default:
+		return nil, fmt.Errorf("reachability must be %s or %s, was '%s'", options.ReachabilityPublic, options.ReachabilityPrivate, opts.ForceReachability)
+	}
+
+	return natOpts, nil
+}
+
+func parseStaticRelays(relayStrs []string) ([]peer.AddrInfo, error) {
+	addrs := make([]multiaddr.Multiaddr, 0, len(relayStrs))
+	for _, relayStr := range relayStrs {
+		addr, err := multiaddr.NewMultiaddr(relayStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid static relay '%s': %w", relayStr, err)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	relays, err := peer.AddrInfosFromP2pAddrs(addrs...)
+	if err != nil {
+		return nil, fmt.Errorf("static relays must include the relay peer ID: %w", err)
+	}
+
+	return relays, nil
+}
@@ -5,8 +5,11 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,9 +17,11 @@ import (
 	koinosmq "github.com/koinos/koinos-mq-golang"
 	"github.com/koinos/koinos-p2p/internal/options"
 	"github.com/koinos/koinos-p2p/internal/p2p"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
 	"github.com/koinos/koinos-p2p/internal/rpc"
 	"github.com/koinos/koinos-proto-golang/koinos"
 	"github.com/koinos/koinos-proto-golang/koinos/broadcast"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
 	util "github.com/koinos/koinos-util-golang"
 
 	libp2p "github.com/libp2p/go-libp2p"
@@ -24,8 +29,10 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/pnet"
 	"github.com/libp2p/go-libp2p-core/routing"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
+	pstoremem "github.com/libp2p/go-libp2p-peerstore/pstoremem"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	multiaddr "github.com/multiformats/go-multiaddr"
@@ -40,22 +47,42 @@ type KoinosP2PNode struct {
 	Gossip            *p2p.KoinosGossip
 	ConnectionManager *p2p.ConnectionManager
 	PeerErrorHandler  *p2p.PeerErrorHandler
+	OrphanBlockPool   *p2p.OrphanBlockPool
 	GossipToggle      *p2p.GossipToggle
+	SyncProgress      *p2p.SyncProgress
+	Events            *p2p.EventPublisher // Nil when the node does not broadcast
 	libValue          atomic.Value
+	headProgress      headProgress
+	applyTracker      *drainingRPC
+	peerOrigin        *peerOriginRPC
+	cancel            context.CancelFunc
+
+	localServicesAvailable int32
+	localChainID           []byte
+	localHeadHeight        uint64
+	directPushPeers        []peer.ID // Empty when blocks are not pushed to direct peers
+	gossipMutex            sync.Mutex
+	gossipVoted            bool
+	gossipEnabled          bool
 
 	PeerErrorChan        chan p2p.PeerError
+	PeerRewardChan       chan p2p.PeerReward
 	DisconnectPeerChan   chan peer.ID
 	GossipVoteChan       chan p2p.GossipVote
 	PeerDisconnectedChan chan peer.ID
+	SyncedBlocksChan     chan p2p.SyncedBlocks
 
-	Options options.NodeOptions
+	Options      options.NodeOptions
+	healthOpts   options.HealthOptions
+	banStateFile string
 }
 
 // NewKoinosP2PNode creates a libp2p node object listening on the given multiaddress
 // uses secio encryption on the wire
-// listenAddr is a multiaddress string on which to listen
+// listenAddrs are the multiaddress strings on which to listen
+// publisher broadcasts the node's status to other microservices, nil to not broadcast
 // seed is the random seed to use for key generation. Use 0 for a random seed.
-func NewKoinosP2PNode(ctx context.Context, listenAddr string, localRPC rpc.LocalRPC, requestHandler *koinosmq.RequestHandler, seed string, config *options.Config) (*KoinosP2PNode, error) {
+func NewKoinosP2PNode(ctx context.Context, listenAddrs []string, localRPC rpc.LocalRPC, requestHandler *koinosmq.RequestHandler, publisher rpc.Publisher, seed string, config *options.Config) (*KoinosP2PNode, error) {
 	privateKey, err := generatePrivateKey(seed)
 	if err != nil {
 		return nil, err
@@ -63,93 +90,218 @@ func NewKoinosP2PNode(ctx context.Context, listenAddr string, localRPC rpc.Local
 
 	node := new(KoinosP2PNode)
 
+	// Libp2p services started by the node run until it is closed
+	ctx, node.cancel = context.WithCancel(ctx)
+
 	node.Options = config.NodeOptions
+	node.healthOpts = config.HealthOptions
+	node.banStateFile = config.PeerErrorHandlerOptions.BanStateFile
 	node.PeerErrorChan = make(chan p2p.PeerError)
+	node.PeerRewardChan = make(chan p2p.PeerReward)
 	node.DisconnectPeerChan = make(chan peer.ID)
 	node.GossipVoteChan = make(chan p2p.GossipVote)
 	node.PeerDisconnectedChan = make(chan peer.ID)
+	node.SyncedBlocksChan = make(chan p2p.SyncedBlocks)
+
+	node.Events = p2p.NewEventPublisher(publisher)
+
+	errorHandlerOpts := config.PeerErrorHandlerOptions
+	errorHandlerOpts.AllowOnly = errorHandlerOpts.AllowOnly || node.Options.SentryMode
 
 	node.PeerErrorHandler = p2p.NewPeerErrorHandler(
 		node.DisconnectPeerChan,
 		node.PeerErrorChan,
-		config.PeerErrorHandlerOptions)
+		node.PeerRewardChan,
+		node.Events,
+		errorHandlerOpts)
+
+	node.OrphanBlockPool = p2p.NewOrphanBlockPool(config.OrphanBlockPoolOptions)
+
+	transports, err := transportOptions(&config.NodeOptions)
+	if err != nil {
+		node.cancel()
+		return nil, err
+	}
+
+	addrFilter, err := newAddressFilter(&config.NodeOptions)
+	if err != nil {
+		node.cancel()
+		return nil, err
+	}
+
+	natOpts, err := natOptions(&config.NodeOptions)
+	if err != nil {
+		node.cancel()
+		return nil, err
+	}
+
+	var psk pnet.PSK
+	if config.NodeOptions.PrivateNetworkKeyFile != "" {
+		psk, err = loadPrivateNetworkKey(config.NodeOptions.PrivateNetworkKeyFile)
+		if err != nil {
+			node.cancel()
+			return nil, err
+		}
+	}
+
+	privatePeers, err := parsePeerIDs(config.NodeOptions.PrivatePeers)
+	if err != nil {
+		node.cancel()
+		return nil, err
+	}
+
+	directPeers, err := parsePeerAddresses(config.NodeOptions.DirectPeers)
+	if err != nil {
+		node.cancel()
+		return nil, err
+	}
 
 	var idht *dht.IpfsDHT
 
+	dhtOpts := make([]dht.Option, 0)
+	if node.Options.SentryMode {
+		// Other peers cannot reach the node, so it should not be in their routing tables
+		dhtOpts = append(dhtOpts, dht.Mode(dht.ModeClient))
+	}
+
+	var privatePS *privatePeerstore
+	if len(privatePeers) > 0 {
+		privatePS = newPrivatePeerstore(pstoremem.NewPeerstore(), privatePeers)
+		dhtOpts = append(dhtOpts, dht.RoutingTableFilter(func(_ interface{}, p peer.ID) bool {
+			return !privatePS.isPrivate(p)
+		}))
+	}
+
 	options := []libp2p.Option{
-		libp2p.ListenAddrStrings(listenAddr),
+		libp2p.ListenAddrStrings(listenAddrs...),
+		libp2p.ChainOptions(transports...),
+		libp2p.AddrsFactory(addrFilter.filter),
 		libp2p.Identity(privateKey),
-		// Attempt to open ports using uPNP for NATed hosts.
-		libp2p.NATPortMap(),
 		// Let this host use the DHT to find other hosts
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
-			idht, err = dht.New(ctx, h)
-			return idht, err
+			idht, err = dht.New(ctx, h, dhtOpts...)
+			if err != nil || privatePS == nil {
+				return idht, err
+			}
+			return &privatePeerRouting{IpfsDHT: idht, privatePS: privatePS}, nil
 		}),
-		// Let this host use relays and advertise itself on relays if
-		// it finds it is behind NAT. Use libp2p.Relay(options...) to
-		// enable active relays and more.
-		libp2p.EnableAutoRelay(),
-		// If you want to help other peers to figure out if they are behind
-		// NATs, you can launch the server-side of AutoNAT too (AutoRelay
-		// already runs the client)
-		//
-		// This service is highly rate-limited and should not cause any
-		// performance issues.
-		libp2p.EnableNATService(),
+		libp2p.ChainOptions(natOpts...),
 		libp2p.ConnectionGater(node.PeerErrorHandler),
 	}
 
+	if psk != nil {
+		log.Info("Joining private network")
+		options = append(options, libp2p.PrivateNetwork(psk))
+	}
+
+	if privatePS != nil {
+		options = append(options, libp2p.Peerstore(privatePS))
+	}
+
 	host, err := libp2p.New(ctx, options...)
 	if err != nil {
+		node.cancel()
 		return nil, err
 	}
 
 	node.Host = host
-	node.localRPC = localRPC
+	node.applyTracker = &drainingRPC{LocalRPC: localRPC}
+	node.peerOrigin = newPeerOriginRPC(node.applyTracker)
+	node.localRPC = node.peerOrigin
 
 	if requestHandler != nil {
 		requestHandler.SetBroadcastHandler("koinos.block.accept", node.handleBlockBroadcast)
 		requestHandler.SetBroadcastHandler("koinos.transaction.accept", node.handleTransactionBroadcast)
 		requestHandler.SetBroadcastHandler("koinos.block.forks", node.handleForkUpdate)
+		requestHandler.SetRPCHandler(AdminRPCType, node.handleAdminRPC)
 	} else {
 		log.Info("Starting P2P node without broadcast listeners")
 	}
 
-	pubsub.TimeCacheDuration = 60 * time.Second
-	ps, err := pubsub.NewGossipSub(
-		ctx, node.Host,
+	pubsubOpts := []pubsub.Option{
 		pubsub.WithMessageIdFn(generateMessageID),
 		pubsub.WithPeerExchange(true),
-	)
+	}
+	pubsubOpts = append(pubsubOpts, privatePeerScoreOptions(privatePeers, node.Options.SentryMode)...)
+
+	// Sentries are direct peers of the node behind them, as the node is a private peer of its sentries
+	gossipDirectPeers := directPeers
+	if node.Options.SentryMode {
+		sentries, err := parsePeerAddresses(node.Options.InitialPeers)
+		if err != nil {
+			node.Close()
+			return nil, err
+		}
+		gossipDirectPeers = append(append([]peer.AddrInfo{}, directPeers...), sentries...)
+	}
+	pubsubOpts = append(pubsubOpts, gossipDirectPeerOptions(gossipDirectPeers, privatePeers)...)
+
+	pubsub.TimeCacheDuration = 60 * time.Second
+	ps, err := pubsub.NewGossipSub(ctx, node.Host, pubsubOpts...)
 	if err != nil {
+		node.Close()
 		return nil, err
 	}
 
+	submissions := p2p.NewPeerSubmissionHandler(node.localRPC, node.PeerErrorChan, &config.GossipOptions)
+
+	node.ConnectionManager = p2p.NewConnectionManager(
+		node.Host,
+		node.localRPC,
+		submissions,
+		&config.PeerConnectionOptions,
+		node,
+		node.OrphanBlockPool,
+		node.PeerErrorHandler,
+		node.PeerErrorHandler.AccessList,
+		node.Events,
+		node.Options.InitialPeers,
+		node.PeerErrorChan,
+		node.PeerRewardChan,
+		node.GossipVoteChan,
+		node.SyncedBlocksChan,
+		node.PeerDisconnectedChan)
+
+	syncProgressOpts := config.SyncProgressOptions
+	syncProgressOpts.SyncedBlockDelta = config.PeerConnectionOptions.SyncedBlockDelta
+
+	node.SyncProgress = p2p.NewSyncProgress(
+		node.localRPC,
+		publisher,
+		node.SyncedBlocksChan,
+		syncProgressOpts)
+
+	if node.Options.SentryMode {
+		for _, id := range node.ConnectionManager.InitialPeerIDs() {
+			node.PeerErrorHandler.AccessList.AllowPeer(id)
+		}
+	}
+
+	if node.Options.EnableDirectBlockPush {
+		for _, addr := range directPeers {
+			node.directPushPeers = append(node.directPushPeers, addr.ID)
+		}
+	}
+
 	node.Gossip = p2p.NewKoinosGossip(
 		ctx,
 		node.localRPC,
 		ps,
 		node.PeerErrorChan,
+		node.PeerRewardChan,
 		node.Host.ID(),
-		node)
+		node,
+		node.ConnectionManager,
+		node.OrphanBlockPool,
+		submissions,
+		&config.GossipOptions)
 
 	node.GossipToggle = p2p.NewGossipToggle(
-		node.Gossip,
+		node,
 		node.GossipVoteChan,
 		node.PeerDisconnectedChan,
 		config.GossipToggleOptions)
 
-	node.ConnectionManager = p2p.NewConnectionManager(
-		node.Host,
-		node.localRPC,
-		&config.PeerConnectionOptions,
-		node,
-		node.Options.InitialPeers,
-		node.PeerErrorChan,
-		node.GossipVoteChan,
-		node.PeerDisconnectedChan)
-
 	return node, nil
 }
 
@@ -166,8 +318,13 @@ func (n *KoinosP2PNode) handleBlockBroadcast(topic string, data []byte) {
 		log.Warnf("Unable to serialize block from broadcast: %v", err.Error())
 		return
 	}
-	n.Gossip.Block.PublishMessage(context.Background(), binary)
 	log.Infof("Publishing block - %s", util.BlockString(blockBroadcast.Block))
+	n.publishBlock(context.Background(), blockBroadcast.Block, binary)
+
+	err = n.OrphanBlockPool.ApplyChildren(context.Background(), n.localRPC, blockBroadcast.Block.Id)
+	if err != nil {
+		log.Warnf("Orphan block not applied: %s", err)
+	}
 }
 
 func (n *KoinosP2PNode) handleTransactionBroadcast(topic string, data []byte) {
@@ -184,7 +341,63 @@ func (n *KoinosP2PNode) handleTransactionBroadcast(topic string, data []byte) {
 		return
 	}
 	log.Infof("Publishing transaction - %s", util.TransactionString(trxBroadcast.Transaction))
-	n.Gossip.Transaction.PublishMessage(context.Background(), binary)
+	n.publishTransaction(context.Background(), trxBroadcast.Transaction, binary)
+}
+
+// publishBlock gossips a block. While gossip is disabled, a block that did not come from a peer,
+// such as one produced by this node, is pushed directly to synced peers instead.
+// When direct block push is enabled, such a block is also pushed to the direct peers without waiting on gossip.
+func (n *KoinosP2PNode) publishBlock(ctx context.Context, block *protocol.Block, data []byte) {
+	if len(n.directPushPeers) > 0 && !n.peerOrigin.isPeerBlock(block.Id) {
+		go func() {
+			if count, err := n.ConnectionManager.PushBlockToPeers(ctx, block, n.directPushPeers); err != nil {
+				log.Warnf("Unable to push block to direct peers - %s: %s", util.BlockString(block), err)
+			} else {
+				log.Debugf("Pushed block to %v direct peers - %s", count, util.BlockString(block))
+			}
+		}()
+	}
+
+	err := n.Gossip.Block.PublishMessage(ctx, data)
+	if errors.Is(err, p2perrors.ErrGossipDisabled) {
+		// Blocks from peers are already known to the network
+		if n.peerOrigin.isPeerBlock(block.Id) {
+			return
+		}
+
+		var count int
+		if count, err = n.ConnectionManager.PushBlock(ctx, block); err == nil {
+			log.Infof("Gossip disabled, pushed block to %v peers - %s", count, util.BlockString(block))
+			return
+		}
+		err = fmt.Errorf("%w and direct push failed, %s", p2perrors.ErrGossipDisabled, err)
+	}
+
+	if err != nil {
+		log.Warnf("Unable to publish block - %s: %s", util.BlockString(block), err)
+	}
+}
+
+// publishTransaction gossips a transaction, or pushes it directly to synced peers while gossip is disabled.
+// A transaction that came from a peer is not published, since gossip already forwards the peer's message.
+func (n *KoinosP2PNode) publishTransaction(ctx context.Context, transaction *protocol.Transaction, data []byte) {
+	if n.peerOrigin.isPeerTransaction(transaction.Id) {
+		return
+	}
+
+	err := n.Gossip.PublishTransaction(ctx, data)
+	if errors.Is(err, p2perrors.ErrGossipDisabled) {
+		var count int
+		if count, err = n.ConnectionManager.PushTransaction(ctx, transaction); err == nil {
+			log.Infof("Gossip disabled, pushed transaction to %v peers - %s", count, util.TransactionString(transaction))
+			return
+		}
+		err = fmt.Errorf("%w and direct push failed, %s", p2perrors.ErrGossipDisabled, err)
+	}
+
+	if err != nil {
+		log.Warnf("Unable to publish transaction - %s: %s", util.TransactionString(transaction), err)
+	}
 }
 
 func (n *KoinosP2PNode) handleForkUpdate(topic string, data []byte) {
@@ -196,7 +409,36 @@ func (n *KoinosP2PNode) handleForkUpdate(topic string, data []byte) {
 		return
 	}
 
-	n.libValue.Store(*forkHeads.LastIrreversibleBlock)
+	n.libValue.Store(forkHeads.LastIrreversibleBlock)
+}
+
+// EnableGossip satisfies the GossipEnableHandler interface
+//
+// Gossip is deferred while the chain or block store is unreachable.
+func (n *KoinosP2PNode) EnableGossip(ctx context.Context, enable bool) {
+	n.gossipMutex.Lock()
+	defer n.gossipMutex.Unlock()
+
+	n.gossipVoted = enable
+	n.updateGossip(ctx)
+}
+
+// updateGossip enables gossip if it was voted for and the local services are available.
+// When gossip is enabled, pending transactions are pulled from synced peers so that
+// the local mempool is not limited to transactions gossiped from this point on.
+func (n *KoinosP2PNode) updateGossip(ctx context.Context) {
+	enable := n.gossipVoted && n.LocalServicesAvailable()
+	if enable == n.gossipEnabled {
+		return
+	}
+
+	n.gossipEnabled = enable
+	n.Gossip.EnableGossip(ctx, enable)
+	n.Events.GossipStatus(enable)
+
+	if enable {
+		go n.ConnectionManager.SyncPendingTransactions(ctx)
+	}
 }
 
 // PeerStringToAddress Creates a peer.AddrInfo object based on the given connection string
@@ -213,6 +455,25 @@ func (n *KoinosP2PNode) PeerStringToAddress(peerAddr string) (*peer.AddrInfo, er
 	return peer, nil
 }
 
+// parsePeerAddresses parses multiaddresses that include the peer ID, such as the direct peers
+func parsePeerAddresses(addrStrs []string) ([]peer.AddrInfo, error) {
+	addrs := make([]peer.AddrInfo, 0, len(addrStrs))
+	for _, addrStr := range addrStrs {
+		addr, err := multiaddr.NewMultiaddr(addrStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer address '%s': %w", addrStr, err)
+		}
+
+		info, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer address '%s': %w", addrStr, err)
+		}
+		addrs = append(addrs, *info)
+	}
+
+	return addrs, nil
+}
+
 // ConnectToPeerAddress connects to the given peer address
 func (n *KoinosP2PNode) ConnectToPeerAddress(ctx context.Context, peer *peer.AddrInfo) error {
 	return n.Host.Connect(ctx, *peer)
@@ -223,7 +484,7 @@ func (n *KoinosP2PNode) GetConnections() []network.Conn {
 	return n.Host.Network().Conns()
 }
 
-// GetAddressInfo returns the node's address info
+// GetAddressInfo returns the node's address info with the addresses announced to peers
 func (n *KoinosP2PNode) GetAddressInfo() *peer.AddrInfo {
 	return &peer.AddrInfo{
 		ID:    n.Host.ID(),
@@ -231,19 +492,31 @@ func (n *KoinosP2PNode) GetAddressInfo() *peer.AddrInfo {
 	}
 }
 
-// GetAddress returns the peer multiaddress
+// GetAddress returns the first announced peer multiaddress, or only the peer ID if no address is announced
 func (n *KoinosP2PNode) GetAddress() multiaddr.Multiaddr {
 	addrs, _ := peer.AddrInfoToP2pAddrs(n.GetAddressInfo())
 	return addrs[0]
 }
 
-// GetLastIrreversibleBlock returns last irreversible block height and block id of connected node
-func (n *KoinosP2PNode) GetLastIrreversibleBlock() koinos.BlockTopology {
-	return n.libValue.Load().(koinos.BlockTopology)
+// GetListenAddresses returns the multiaddresses the host is listening on, including those not announced
+func (n *KoinosP2PNode) GetListenAddresses() []multiaddr.Multiaddr {
+	return n.Host.Network().ListenAddresses()
+}
+
+// GetLastIrreversibleBlock returns last irreversible block height and block id of connected node.
+// It is empty until the chain has been reached. The block is shared and must not be modified.
+func (n *KoinosP2PNode) GetLastIrreversibleBlock() *koinos.BlockTopology {
+	if lib, ok := n.libValue.Load().(*koinos.BlockTopology); ok && lib != nil {
+		return lib
+	}
+
+	return &koinos.BlockTopology{}
 }
 
 // Close closes the node
 func (n *KoinosP2PNode) Close() error {
+	defer n.cancel()
+
 	if err := n.Host.Close(); err != nil {
 		return err
 	}
@@ -271,23 +544,29 @@ func (n *KoinosP2PNode) logConnectionsLoop(ctx context.Context) {
 	}
 }
 
-// Start starts background goroutines
-func (n *KoinosP2PNode) Start(ctx context.Context) {
-	n.Host.Network().Notify(n.ConnectionManager)
-
-	forkHeads, err := n.localRPC.GetForkHeads(ctx)
+// Start starts background goroutines, returning a Handle to stop them
+func (n *KoinosP2PNode) Start(ctx context.Context) *Handle {
+	ctx, cancel := context.WithCancel(ctx)
 
-	for err != nil {
-		forkHeads, err = n.localRPC.GetForkHeads(ctx)
-	}
+	n.Host.Network().Notify(n.ConnectionManager)
 
-	n.libValue.Store(*forkHeads.LastIrreversibleBlock)
+	n.Events.Start(ctx)
 
 	// Start peer gossip
 	go n.logConnectionsLoop(ctx)
 	n.PeerErrorHandler.Start(ctx)
+	n.Host.Network().Notify(n.PeerErrorHandler)
+	n.OrphanBlockPool.Start(ctx)
 	n.GossipToggle.Start(ctx)
 	n.ConnectionManager.Start(ctx)
+	n.SyncProgress.Start(ctx)
+
+	// Networking starts immediately, while sync and gossip wait for the chain and block store
+	go n.monitorLocalServices(ctx)
+
+	if n.healthOpts.ListenAddress != "" {
+		n.startHealthServer(ctx)
+	}
 
 	go func() {
 		for {
@@ -299,6 +578,8 @@ func (n *KoinosP2PNode) Start(ctx context.Context) {
 			}
 		}
 	}()
+
+	return &Handle{node: n, cancel: cancel}
 }
 
 // ----------------------------------------------------------------------------
@@ -338,7 +619,7 @@ func generatePrivateKey(seed string) (crypto.PrivKey, error) {
 func generateMessageID(msg *pb.Message) string {
 	// Use the default unique ID function for peer exchange
 	switch *msg.Topic {
-	case p2p.BlockTopicName, p2p.TransactionTopicName:
+	case p2p.BlockTopicName, p2p.TransactionTopicName, p2p.TransactionBatchTopicName:
 		// Hash the data
 		h := sha256.New()
 		h.Write(msg.Data)
@@ -1,18 +1,34 @@
 package node
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/koinos/koinos-p2p/internal/events"
 	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2p"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/testutil"
 	"github.com/koinos/koinos-proto-golang/koinos"
+	"github.com/koinos/koinos-proto-golang/koinos/broadcast"
 	"github.com/koinos/koinos-proto-golang/koinos/protocol"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/mempool"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multihash"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
 )
 
 type TestRPC struct {
@@ -23,6 +39,7 @@ type TestRPC struct {
 	ApplyBlocks      int    // Number of blocks to apply before failure. < 0 = always apply
 	BlocksByID       map[string]*protocol.Block
 	BlocksByHeight   map[uint64]*protocol.Block
+	TrxsApplied      []*protocol.Transaction
 	Mutex            sync.Mutex
 }
 
@@ -31,7 +48,7 @@ func (k *TestRPC) GetHeadBlock(ctx context.Context) (*chain.GetHeadInfoResponse,
 	k.Mutex.Lock()
 	defer k.Mutex.Unlock()
 
-	hi := chain.GetHeadInfoResponse{}
+	hi := chain.GetHeadInfoResponse{HeadTopology: &koinos.BlockTopology{}}
 	hi.HeadTopology.Height = k.Height
 	hi.HeadTopology.Id, _ = multihash.Encode(make([]byte, 0), k.Height+k.HeadBlockIDDelta)
 	binary.PutUvarint(hi.HeadTopology.Id, k.Height+k.HeadBlockIDDelta)
@@ -53,10 +70,28 @@ func (k *TestRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain
 	return &chain.SubmitBlockResponse{}, nil
 }
 
-func (k *TestRPC) ApplyTransaction(ctx context.Context, block *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+func (k *TestRPC) ApplyTransaction(ctx context.Context, trx *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.TrxsApplied = append(k.TrxsApplied, trx)
+
 	return &chain.SubmitTransactionResponse{}, nil
 }
 
+func (k *TestRPC) ApplyTransactions(ctx context.Context, trxs []*protocol.Transaction) []error {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.TrxsApplied = append(k.TrxsApplied, trxs...)
+
+	return make([]error, len(trxs))
+}
+
+func (k *TestRPC) GetPendingTransactions(ctx context.Context, limit uint64) (*mempool.GetPendingTransactionsResponse, error) {
+	return &mempool.GetPendingTransactionsResponse{}, nil
+}
+
 func (k *TestRPC) GetForkHeads(ctx context.Context) (*chain.GetForkHeadsResponse, error) {
 	k.Mutex.Lock()
 	defer k.Mutex.Unlock()
@@ -127,7 +162,7 @@ func TestBasicNode(t *testing.T) {
 	rpc := NewTestRPC(128)
 
 	// With an explicit seed
-	bn, err := NewKoinosP2PNode(ctx, "/ip4/127.0.0.1/tcp/8765", rpc, nil, "test1", options.NewConfig())
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", options.NewConfig())
 	if err != nil {
 		t.Error(err)
 	}
@@ -141,7 +176,7 @@ func TestBasicNode(t *testing.T) {
 	bn.Close()
 
 	// With blank seed
-	bn, err = NewKoinosP2PNode(ctx, "/ip4/127.0.0.1/tcp/8765", rpc, nil, "", options.NewConfig())
+	bn, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "", options.NewConfig())
 	if err != nil {
 		t.Error(err)
 	}
@@ -149,9 +184,888 @@ func TestBasicNode(t *testing.T) {
 	bn.Close()
 
 	// Give an invalid listen address
-	bn, err = NewKoinosP2PNode(ctx, "---", rpc, nil, "", options.NewConfig())
+	bn, err = NewKoinosP2PNode(ctx, []string{"---"}, rpc, nil, nil, "", options.NewConfig())
 	if err == nil {
 		bn.Close()
 		t.Error("Starting a node with an invalid address should give an error, but it did not")
 	}
 }
+
+func isListeningOn(n *KoinosP2PNode, addr string) bool {
+	for _, listenAddr := range n.GetListenAddresses() {
+		if listenAddr.String() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestTransports(t *testing.T) {
+	ctx := context.Background()
+
+	// Listen on TCP and WebSocket at the same time
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765", "/ip4/127.0.0.1/tcp/8766/ws"}, NewTestRPC(128), nil, nil, "test1", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isListeningOn(bn, "/ip4/127.0.0.1/tcp/8765") || !isListeningOn(bn, "/ip4/127.0.0.1/tcp/8766/ws") {
+		t.Errorf("Node should listen on both addresses, but listens on %v", bn.GetListenAddresses())
+	}
+
+	bn.Close()
+
+	// A transport that is not enabled cannot be listened on
+	config := options.NewConfig()
+	config.NodeOptions.EnableWebSocket = false
+	bn, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766/ws"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err == nil {
+		bn.Close()
+		t.Error("Listening on a disabled transport should give an error, but it did not")
+	}
+
+	config = options.NewConfig()
+	config.NodeOptions.EnableTCP = false
+	config.NodeOptions.EnableWebSocket = false
+	_, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if !errors.Is(err, ErrNoTransports) {
+		t.Errorf("Expected ErrNoTransports, was %v", err)
+	}
+}
+
+func TestAnnounceAddresses(t *testing.T) {
+	ctx := context.Background()
+
+	// Announced addresses replace the listen addresses
+	config := options.NewConfig()
+	config.NodeOptions.AnnounceAddresses = []string{"/ip4/1.2.3.4/tcp/8888", "/ip4/10.0.0.1/tcp/8888", "/ip4/5.6.7.8/tcp/8888"}
+	config.NodeOptions.NoAnnounceAddresses = []string{"/ip4/5.6.7.8/tcp/8888"}
+	config.NodeOptions.HidePrivateAddresses = true
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := bn.GetAddressInfo().Addrs
+	if len(addrs) != 1 || addrs[0].String() != "/ip4/1.2.3.4/tcp/8888" {
+		t.Errorf("Incorrect announced addresses. Expected [/ip4/1.2.3.4/tcp/8888], was %v", addrs)
+	}
+
+	bn.Close()
+
+	// Listen addresses in a hidden range are not announced, but are still listened on
+	config = options.NewConfig()
+	config.NodeOptions.NoAnnounceAddresses = []string{"127.0.0.0/8"}
+
+	bn, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bn.GetAddressInfo().Addrs) != 0 {
+		t.Errorf("Hidden addresses should not be announced, but were %v", bn.GetAddressInfo().Addrs)
+	}
+
+	if !isListeningOn(bn, "/ip4/127.0.0.1/tcp/8765") {
+		t.Errorf("Node should still listen on hidden addresses, but listens on %v", bn.GetListenAddresses())
+	}
+
+	bn.Close()
+
+	config = options.NewConfig()
+	config.NodeOptions.NoAnnounceAddresses = []string{"not an address"}
+	_, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err == nil {
+		t.Error("Starting a node with an invalid no-announce address should give an error, but it did not")
+	}
+}
+
+func TestNATOptions(t *testing.T) {
+	ctx := context.Background()
+
+	const relayAddr = "/ip4/1.2.3.4/tcp/8888/p2p/QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+
+	// A public node that neither maps ports nor relays
+	config := options.NewConfig()
+	config.NodeOptions.EnableNATPortMap = false
+	config.NodeOptions.EnableRelay = false
+	config.NodeOptions.EnableAutoRelay = false
+	config.NodeOptions.ForceReachability = options.ReachabilityPublic
+	config.NodeOptions.ExternalAddresses = []string{"/ip4/1.2.3.4/tcp/8765"}
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := bn.GetAddressInfo().Addrs
+	if len(addrs) != 2 || addrs[1].String() != "/ip4/1.2.3.4/tcp/8765" {
+		t.Errorf("External address should be announced with the listen address, but was %v", addrs)
+	}
+
+	bn.Close()
+
+	// A relay client only
+	config = options.NewConfig()
+	config.NodeOptions.EnableNATService = false
+	config.NodeOptions.StaticRelays = []string{relayAddr}
+
+	bn, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bn.Close()
+
+	incompatible := []func(*options.NodeOptions){
+		func(o *options.NodeOptions) { o.EnableRelay = false },
+		func(o *options.NodeOptions) { o.EnableRelay, o.EnableAutoRelay, o.EnableRelayHop = false, false, true },
+		func(o *options.NodeOptions) { o.EnableAutoRelay, o.StaticRelays = false, []string{relayAddr} },
+		func(o *options.NodeOptions) { o.EnableRelayHop, o.StaticRelays = true, []string{relayAddr} },
+		func(o *options.NodeOptions) { o.ForceReachability = options.ReachabilityPublic },
+		func(o *options.NodeOptions) {
+			o.EnableRelayHop, o.ForceReachability = true, options.ReachabilityPrivate
+		},
+	}
+
+	for i, setOptions := range incompatible {
+		config = options.NewConfig()
+		setOptions(&config.NodeOptions)
+
+		_, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+		if !errors.Is(err, ErrIncompatibleOptions) {
+			t.Errorf("Expected ErrIncompatibleOptions for combination %v, was %v", i, err)
+		}
+	}
+
+	config = options.NewConfig()
+	config.NodeOptions.ForceReachability = "sometimes"
+	if _, err = NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config); err == nil {
+		t.Error("Starting a node with an invalid reachability should give an error, but it did not")
+	}
+}
+
+func TestAdminRPC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := options.NewConfig()
+	config.PeerErrorHandlerOptions.ErrorHistorySize = 2
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+
+	bn.PeerErrorHandler.Start(ctx)
+
+	const peerStr = "QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+	id, _ := peer.Decode(peerStr)
+	bn.PeerErrorChan <- p2p.NewPeerError(id, p2perrors.ErrPeerRPC)
+	bn.PeerErrorChan <- p2p.NewPeerError(id, p2perrors.ErrDeserialization)
+	bn.PeerErrorChan <- p2p.NewPeerError(id, p2perrors.ErrPeerRPCTimeout)
+
+	data, err := bn.handleAdminRPC(AdminRPCType, []byte(`{"method":"get_peer_errors","params":{"peer_id":"`+peerStr+`"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := struct {
+		Result map[string][]p2p.PeerErrorRecord `json:"result"`
+		Error  string                           `json:"error"`
+	}{}
+	if err = json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Error != "" {
+		t.Errorf("Unexpected admin rpc error: %s", response.Error)
+	}
+
+	// Only the most recent errors are kept
+	history := response.Result[peerStr]
+	if len(history) != 2 {
+		t.Fatalf("Incorrect error history length. Expected 2, was %v", len(history))
+	}
+
+	if history[0].Category != options.DeserializationError || history[1].Category != options.PeerRPCTimeoutError {
+		t.Errorf("Incorrect error history. Was %v", history)
+	}
+
+	if history[1].ScoreDelta != config.PeerErrorHandlerOptions.ErrorScores[options.PeerRPCTimeoutError].Score {
+		t.Errorf("Incorrect score delta in error history")
+	}
+
+	data, _ = bn.handleAdminRPC(AdminRPCType, []byte(`{"method":"not_a_method"}`))
+	if err = json.Unmarshal(data, &response); err != nil || response.Error == "" {
+		t.Errorf("Unknown admin rpc method should give an error, but it did not")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+
+	bn.Start(ctx)
+
+	status := bn.GetHealth(ctx)
+	if !status.ConnectedToChain || !status.ConnectedToBlockStore {
+		t.Errorf("Expected node to be connected to chain and block store")
+	}
+
+	if status.HeadHeight != 128 {
+		t.Errorf("Incorrect head height. Expected 128, was %v", status.HeadHeight)
+	}
+
+	// A node without peers is not healthy
+	if status.Healthy || status.HandshakedPeers != 0 {
+		t.Errorf("Expected node without peers to be unhealthy")
+	}
+
+	recorder := httptest.NewRecorder()
+	bn.handleHealth(recorder, httptest.NewRequest("GET", HealthPath, nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Incorrect status code. Expected %v, was %v", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	response := HealthStatus{}
+	if err = json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.HeadHeight != 128 || len(response.Problems) == 0 {
+		t.Errorf("Incorrect health response. Was %v", response)
+	}
+
+	// The head is only stalled once it has not advanced for the stall duration
+	progress := headProgress{}
+	if progress.update(10) != 0 {
+		t.Errorf("Expected new head to reset stall time")
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	if progress.update(10) < time.Millisecond*10 || progress.update(11) != 0 {
+		t.Errorf("Incorrect stall time")
+	}
+}
+
+func TestPeerLatency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := options.NewConfig()
+	config.PeerConnectionOptions.PingInterval = time.Millisecond * 50
+
+	// Both nodes are at their LIB, so neither requests blocks from the other
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+	bn.Start(ctx)
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the peer to respond to a ping
+	for i := 0; i < 40; i++ {
+		statuses := bn.ConnectionManager.GetPeerStatus(ctx)
+		if len(statuses) == 1 && statuses[0].RTT > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	data, err := bn.handleAdminRPC(AdminRPCType, []byte(`{"method":"get_peers"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := struct {
+		Result []PeerInfo `json:"result"`
+		Error  string     `json:"error"`
+	}{}
+	if err = json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Result) != 1 || response.Result[0].PeerID != peerNode.Host.ID().Pretty() {
+		t.Fatalf("Incorrect peers. Was %v", response.Result)
+	}
+
+	if response.Result[0].RTT <= 0 {
+		t.Errorf("Expected peer RTT to be measured, was %v", response.Result[0].RTT)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newMetricsCollector(bn))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rttMetrics := 0
+	for _, family := range families {
+		if family.GetName() == "koinos_p2p_peer_rtt_seconds" {
+			rttMetrics = len(family.GetMetric())
+		}
+	}
+
+	if rttMetrics != 1 {
+		t.Errorf("Incorrect number of peer RTT metrics. Expected 1, was %v", rttMetrics)
+	}
+}
+
+func TestPeerEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Both nodes are at their LIB, so neither requests blocks from the other
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	publisher := &testutil.TestPublisher{}
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, publisher, "test1", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+	bn.Start(ctx)
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForEvent := func(topic string) []byte {
+		for i := 0; i < 40; i++ {
+			if data := publisher.Find(topic); data != nil {
+				return data
+			}
+			time.Sleep(time.Millisecond * 50)
+		}
+		t.Fatalf("Expected %s event", topic)
+		return nil
+	}
+
+	connected := &events.PeerConnected{}
+	if err = proto.Unmarshal(waitForEvent(p2p.PeerConnectedTopic), connected); err != nil {
+		t.Fatal(err)
+	}
+
+	if connected.PeerId != peerNode.Host.ID().Pretty() || connected.Address == "" {
+		t.Errorf("Incorrect peer connected event. Was %v", connected)
+	}
+
+	handshaked := &events.PeerHandshaked{}
+	if err = proto.Unmarshal(waitForEvent(p2p.PeerHandshakedTopic), handshaked); err != nil {
+		t.Fatal(err)
+	}
+
+	if handshaked.PeerId != peerNode.Host.ID().Pretty() {
+		t.Errorf("Incorrect peer handshaked event. Was %v", handshaked)
+	}
+
+	// Synced peers vote for gossip
+	gossipStatus := &events.GossipStatus{}
+	if err = proto.Unmarshal(waitForEvent(p2p.GossipStatusTopic), gossipStatus); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gossipStatus.Enabled {
+		t.Errorf("Expected gossip enabled event")
+	}
+
+	expiration := time.Now().Add(time.Hour)
+	bn.PeerErrorHandler.BanPeer(ctx, peerNode.Host.ID(), expiration, "test ban")
+
+	banned := &events.PeerBanned{}
+	if err = proto.Unmarshal(waitForEvent(p2p.PeerBannedTopic), banned); err != nil {
+		t.Fatal(err)
+	}
+
+	if banned.PeerId != peerNode.Host.ID().Pretty() || banned.Reason != "test ban" || banned.Expiration != uint64(expiration.UnixNano()/int64(time.Millisecond)) {
+		t.Errorf("Incorrect peer banned event. Was %v", banned)
+	}
+
+	disconnected := &events.PeerDisconnected{}
+	if err = proto.Unmarshal(waitForEvent(p2p.PeerDisconnectedTopic), disconnected); err != nil {
+		t.Fatal(err)
+	}
+
+	if disconnected.PeerId != peerNode.Host.ID().Pretty() {
+		t.Errorf("Incorrect peer disconnected event. Was %v", disconnected)
+	}
+}
+
+func TestDirectPushWhileGossipDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Both nodes are at their LIB, so neither requests blocks from the other
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	config := options.NewConfig()
+	config.GossipToggleOptions.AlwaysDisable = true
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+	bn.Start(ctx)
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 40 && len(bn.ConnectionManager.GetSyncedPeers(ctx)) == 0; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	newBlock := func(height uint64) *protocol.Block {
+		block := &protocol.Block{Header: &protocol.BlockHeader{Height: height}}
+		block.Id, _ = multihash.Encode(make([]byte, 0), height)
+		block.Header.Previous, _ = multihash.Encode(make([]byte, 0), height-1)
+		return block
+	}
+
+	broadcastBlock := func(block *protocol.Block) {
+		data, err := proto.Marshal(&broadcast.BlockAccepted{Block: block})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bn.handleBlockBroadcast("koinos.block.accept", data)
+	}
+
+	// A locally produced block is pushed to the peer
+	produced := newBlock(129)
+	broadcastBlock(produced)
+
+	peerRPC.Mutex.Lock()
+	_, ok := peerRPC.BlocksByID[string(produced.Id)]
+	peerRPC.Mutex.Unlock()
+	if !ok {
+		t.Errorf("Expected locally produced block to be pushed to peer")
+	}
+
+	// A block received from a peer is not pushed back out
+	received := newBlock(130)
+	if _, err = bn.localRPC.ApplyBlock(ctx, received); err != nil {
+		t.Fatal(err)
+	}
+	broadcastBlock(received)
+
+	peerRPC.Mutex.Lock()
+	_, ok = peerRPC.BlocksByID[string(received.Id)]
+	peerRPC.Mutex.Unlock()
+	if ok {
+		t.Errorf("Expected block received from a peer not to be pushed")
+	}
+
+	newTransaction := func(nonce uint64) *protocol.Transaction {
+		transaction := &protocol.Transaction{}
+		transaction.Id, _ = multihash.Encode(make([]byte, 0), nonce)
+		return transaction
+	}
+
+	broadcastTransaction := func(transaction *protocol.Transaction) {
+		data, err := proto.Marshal(&broadcast.TransactionAccepted{Transaction: transaction})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bn.handleTransactionBroadcast("koinos.transaction.accept", data)
+	}
+
+	peerTransactionApplied := func(transaction *protocol.Transaction) bool {
+		peerRPC.Mutex.Lock()
+		defer peerRPC.Mutex.Unlock()
+
+		for _, applied := range peerRPC.TrxsApplied {
+			if bytes.Equal(applied.Id, transaction.Id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A locally submitted transaction is pushed to the peer
+	submitted := newTransaction(1)
+	broadcastTransaction(submitted)
+
+	if !peerTransactionApplied(submitted) {
+		t.Errorf("Expected locally submitted transaction to be pushed to peer")
+	}
+
+	// A transaction received from a peer is not pushed back out
+	receivedTransaction := newTransaction(2)
+	if _, err = bn.localRPC.ApplyTransaction(ctx, receivedTransaction); err != nil {
+		t.Fatal(err)
+	}
+	broadcastTransaction(receivedTransaction)
+
+	if peerTransactionApplied(receivedTransaction) {
+		t.Errorf("Expected transaction received from a peer not to be pushed")
+	}
+}
+
+func TestDirectBlockPush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	// A direct peer that is offline does not receive the block
+	offlineNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8767"}, NewTestRPC(128), nil, nil, "test3", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	offlineNode.Close()
+
+	config := options.NewConfig()
+	config.NodeOptions.EnableDirectBlockPush = true
+	config.NodeOptions.DirectPeers = []string{
+		"/ip4/127.0.0.1/tcp/8766/p2p/" + peerNode.Host.ID().Pretty(),
+		"/ip4/127.0.0.1/tcp/8767/p2p/" + offlineNode.Host.ID().Pretty(),
+	}
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+
+	if len(bn.directPushPeers) != 2 {
+		t.Fatalf("Expected 2 direct push peers, was %v", len(bn.directPushPeers))
+	}
+
+	bn.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	block := &protocol.Block{Header: &protocol.BlockHeader{Height: 129}}
+	block.Id, _ = multihash.Encode(make([]byte, 0), 129)
+	block.Header.Previous, _ = multihash.Encode(make([]byte, 0), 128)
+
+	count, err := bn.ConnectionManager.PushBlockToPeers(ctx, block, bn.directPushPeers)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected block to be pushed to 1 direct peer, was %v: %v", count, err)
+	}
+
+	peerRPC.Mutex.Lock()
+	_, ok := peerRPC.BlocksByID[string(block.Id)]
+	peerRPC.Mutex.Unlock()
+	if !ok {
+		t.Errorf("Expected pushed block to be applied by direct peer")
+	}
+
+	// The direct peer accepts a duplicate push of the block
+	count, err = bn.ConnectionManager.PushBlockToPeers(ctx, block, bn.directPushPeers)
+	if err != nil || count != 1 {
+		t.Errorf("Expected duplicate block push to be accepted, was %v: %v", count, err)
+	}
+}
+
+func TestTransactionBatching(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	config := options.NewConfig()
+	config.GossipToggleOptions.AlwaysEnable = true
+	config.GossipOptions.EnableTransactionBatching = true
+	config.GossipOptions.TransactionBatchMaxSize = 3
+	config.GossipOptions.TransactionBatchMaxDelay = time.Millisecond * 50
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+	bn.Start(ctx)
+
+	peerConfig := options.NewConfig()
+	peerConfig.GossipToggleOptions.AlwaysEnable = true
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", peerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && len(bn.Gossip.PubSub.ListPeers(p2p.TransactionBatchTopicName)) == 0; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// Wait for the gossip mesh, which is formed on the first heartbeat
+	time.Sleep(time.Millisecond * 1500)
+
+	broadcastTransaction := func(id uint64) {
+		transaction := &protocol.Transaction{}
+		transaction.Id, _ = multihash.Encode(make([]byte, 0), id)
+		data, err := proto.Marshal(&broadcast.TransactionAccepted{Transaction: transaction})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bn.handleTransactionBroadcast("koinos.transaction.accept", data)
+	}
+
+	appliedCount := func() int {
+		peerRPC.Mutex.Lock()
+		defer peerRPC.Mutex.Unlock()
+		return len(peerRPC.TrxsApplied)
+	}
+
+	// A full batch is published immediately and a partial batch after the max delay
+	for i := uint64(1); i <= 4; i++ {
+		broadcastTransaction(i)
+	}
+
+	for i := 0; i < 40 && appliedCount() < 4; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	if count := appliedCount(); count != 4 {
+		t.Errorf("Expected 4 batched transactions to be applied by peer, was %v", count)
+	}
+
+	// Transactions from peers are not batched again
+	received := &protocol.Transaction{}
+	received.Id, _ = multihash.Encode(make([]byte, 0), 5)
+	bn.localRPC.ApplyTransactions(ctx, []*protocol.Transaction{received})
+	broadcastTransaction(5)
+
+	time.Sleep(time.Millisecond * 200)
+	if count := appliedCount(); count != 4 {
+		t.Errorf("Expected transaction received from a peer not to be batched, peer applied %v transactions", count)
+	}
+}
+
+func TestPeerTransactionNotRepublished(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rpc := NewTestRPC(128)
+	rpc.LastIrreversible = rpc.Height
+	peerRPC := NewTestRPC(128)
+	peerRPC.LastIrreversible = peerRPC.Height
+
+	config := options.NewConfig()
+	config.GossipToggleOptions.AlwaysEnable = true
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+	bn.Start(ctx)
+
+	peerConfig := options.NewConfig()
+	peerConfig.GossipToggleOptions.AlwaysEnable = true
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8766"}, peerRPC, nil, nil, "test2", peerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+	peerNode.Start(ctx)
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && len(bn.Gossip.PubSub.ListPeers(p2p.TransactionTopicName)) == 0; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// Wait for the gossip mesh, which is formed on the first heartbeat
+	time.Sleep(time.Millisecond * 1500)
+
+	broadcastTransaction := func(id uint64) {
+		transaction := &protocol.Transaction{}
+		transaction.Id, _ = multihash.Encode(make([]byte, 0), id)
+		data, err := proto.Marshal(&broadcast.TransactionAccepted{Transaction: transaction})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bn.handleTransactionBroadcast("koinos.transaction.accept", data)
+	}
+
+	appliedCount := func() int {
+		peerRPC.Mutex.Lock()
+		defer peerRPC.Mutex.Unlock()
+		return len(peerRPC.TrxsApplied)
+	}
+
+	broadcastTransaction(1)
+
+	for i := 0; i < 40 && appliedCount() < 1; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	if count := appliedCount(); count != 1 {
+		t.Fatalf("Expected the transaction to be applied by peer, was %v", count)
+	}
+
+	// Transactions from peers are already forwarded by gossip, so they are not published again
+	received := &protocol.Transaction{}
+	received.Id, _ = multihash.Encode(make([]byte, 0), 2)
+	bn.localRPC.ApplyTransaction(ctx, received)
+	broadcastTransaction(2)
+
+	time.Sleep(time.Millisecond * 200)
+	if count := appliedCount(); count != 1 {
+		t.Errorf("Expected transaction received from a peer not to be published, peer applied %v transactions", count)
+	}
+}
+
+func TestCheckLocalServices(t *testing.T) {
+	ctx := context.Background()
+	rpc := NewTestRPC(128)
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/tcp/8765"}, rpc, nil, nil, "test1", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+
+	if available, restarted := bn.checkLocalServices(ctx); !available || restarted {
+		t.Fatalf("Expected available and not restarted on first check, was %v and %v", available, restarted)
+	}
+
+	// The head advancing is not a restart
+	rpc.Mutex.Lock()
+	rpc.Height = 130
+	rpc.Mutex.Unlock()
+	if available, restarted := bn.checkLocalServices(ctx); !available || restarted {
+		t.Errorf("Expected advancing head not to be a restart, was %v and %v", available, restarted)
+	}
+
+	// The head moving backwards, while still ahead of the last irreversible block, is a restart
+	rpc.Mutex.Lock()
+	rpc.Height = 126
+	rpc.Mutex.Unlock()
+	if available, restarted := bn.checkLocalServices(ctx); !available || !restarted {
+		t.Errorf("Expected head moving backwards to be a restart, was %v and %v", available, restarted)
+	}
+
+	if _, restarted := bn.checkLocalServices(ctx); restarted {
+		t.Errorf("Expected the new head to be accepted after a restart")
+	}
+
+	// A different chain ID is a restart
+	rpc.Mutex.Lock()
+	rpc.ChainID = 2
+	rpc.Mutex.Unlock()
+	if available, restarted := bn.checkLocalServices(ctx); !available || !restarted {
+		t.Errorf("Expected chain ID change to be a restart, was %v and %v", available, restarted)
+	}
+
+	if _, restarted := bn.checkLocalServices(ctx); restarted {
+		t.Errorf("Expected the new chain ID to be accepted after a restart")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	goroutines := testutil.GoroutineCount()
+
+	config := options.NewConfig()
+	config.PeerErrorHandlerOptions.BanStateFile = filepath.Join(t.TempDir(), "bans.json")
+
+	bn, err := NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := bn.Start(context.Background())
+
+	id, _ := peer.Decode("QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N")
+	bn.PeerErrorHandler.AccessList.AddBan(&p2p.Ban{Peer: id, Reason: "testing"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = handle.Stop(ctx); err != nil {
+		t.Error(err)
+	}
+
+	// Stopping again is a no-op
+	if err = handle.Stop(ctx); err != nil {
+		t.Error(err)
+	}
+
+	// Blocks are not applied once the node is stopping
+	if _, err = bn.localRPC.ApplyBlock(ctx, &protocol.Block{}); !errors.Is(err, p2perrors.ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown, was %v", err)
+	}
+
+	testutil.WaitForGoroutines(t, goroutines)
+
+	// Bans are restored on the next start
+	accessList := p2p.NewAccessList(config.PeerErrorHandlerOptions)
+	if ban, ok := accessList.GetPeerBan(id); !ok || ban.Reason != "testing" || !ban.Expiration.IsZero() {
+		t.Errorf("Expected saved ban to be restored")
+	}
+}
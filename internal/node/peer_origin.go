@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+
+	"github.com/koinos/koinos-p2p/internal/p2p"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+)
+
+// peerOriginCacheSize is how many recently submitted block and transaction IDs are remembered
+const peerOriginCacheSize = 4096
+
+// peerOriginRPC remembers the blocks and transactions that came from peers, so that the node can tell
+// them apart from locally produced ones when the chain and mempool broadcast that they were accepted
+type peerOriginRPC struct {
+	rpc.LocalRPC
+
+	blocks       *p2p.RecentIDs
+	transactions *p2p.RecentIDs
+}
+
+func newPeerOriginRPC(localRPC rpc.LocalRPC) *peerOriginRPC {
+	return &peerOriginRPC{
+		LocalRPC:     localRPC,
+		blocks:       p2p.NewRecentIDs(peerOriginCacheSize),
+		transactions: p2p.NewRecentIDs(peerOriginCacheSize),
+	}
+}
+
+// ApplyBlock rpc call
+//
+// The block is remembered before it is applied, because the chain broadcasts that it
+// accepted the block before responding.
+func (r *peerOriginRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	r.blocks.Add(block.Id)
+	return r.LocalRPC.ApplyBlock(ctx, block)
+}
+
+// ApplyTransaction rpc call
+func (r *peerOriginRPC) ApplyTransaction(ctx context.Context, transaction *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+	r.transactions.Add(transaction.Id)
+	return r.LocalRPC.ApplyTransaction(ctx, transaction)
+}
+
+// ApplyTransactions rpc call
+func (r *peerOriginRPC) ApplyTransactions(ctx context.Context, transactions []*protocol.Transaction) []error {
+	for _, transaction := range transactions {
+		r.transactions.Add(transaction.Id)
+	}
+	return r.LocalRPC.ApplyTransactions(ctx, transactions)
+}
+
+// isPeerBlock returns if the block was recently received from a peer
+func (r *peerOriginRPC) isPeerBlock(id []byte) bool {
+	return r.blocks.Contains(id)
+}
+
+// isPeerTransaction returns if the transaction was recently received from a peer
+func (r *peerOriginRPC) isPeerTransaction(id []byte) bool {
+	return r.transactions.Contains(id)
+}
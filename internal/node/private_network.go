@@ -0,0 +1,24 @@
+package node
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/libp2p/go-libp2p-core/pnet"
+)
+
+// loadPrivateNetworkKey reads a pre-shared key in the libp2p swarm key format
+func loadPrivateNetworkKey(path string) (pnet.PSK, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open private network key: %w", err)
+	}
+	defer file.Close()
+
+	psk, err := pnet.DecodeV1PSK(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode private network key '%s': %w", path, err)
+	}
+
+	return psk, nil
+}
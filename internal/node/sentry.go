@@ -0,0 +1,191 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-core/record"
+	"github.com/libp2p/go-libp2p-core/routing"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// privatePeerstore never stores the addresses or peer records of private peers, so that
+// they cannot be shared with other peers through the DHT, identify, or gossipsub peer exchange.
+// Private peers must dial the node, since the node does not know where to reach them.
+type privatePeerstore struct {
+	peerstore.Peerstore
+	privatePeers map[peer.ID]bool
+}
+
+func newPrivatePeerstore(ps peerstore.Peerstore, privatePeers []peer.ID) *privatePeerstore {
+	p := &privatePeerstore{
+		Peerstore:    ps,
+		privatePeers: make(map[peer.ID]bool),
+	}
+
+	for _, id := range privatePeers {
+		p.privatePeers[id] = true
+	}
+
+	return p
+}
+
+func (ps *privatePeerstore) isPrivate(id peer.ID) bool {
+	return ps.privatePeers[id]
+}
+
+// AddAddr is part of the peerstore.AddrBook interface
+func (ps *privatePeerstore) AddAddr(p peer.ID, addr multiaddr.Multiaddr, ttl time.Duration) {
+	if !ps.isPrivate(p) {
+		ps.Peerstore.AddAddr(p, addr, ttl)
+	}
+}
+
+// AddAddrs is part of the peerstore.AddrBook interface
+func (ps *privatePeerstore) AddAddrs(p peer.ID, addrs []multiaddr.Multiaddr, ttl time.Duration) {
+	if !ps.isPrivate(p) {
+		ps.Peerstore.AddAddrs(p, addrs, ttl)
+	}
+}
+
+// SetAddr is part of the peerstore.AddrBook interface
+func (ps *privatePeerstore) SetAddr(p peer.ID, addr multiaddr.Multiaddr, ttl time.Duration) {
+	if !ps.isPrivate(p) {
+		ps.Peerstore.SetAddr(p, addr, ttl)
+	}
+}
+
+// SetAddrs is part of the peerstore.AddrBook interface
+func (ps *privatePeerstore) SetAddrs(p peer.ID, addrs []multiaddr.Multiaddr, ttl time.Duration) {
+	if !ps.isPrivate(p) {
+		ps.Peerstore.SetAddrs(p, addrs, ttl)
+	}
+}
+
+// ConsumePeerRecord is part of the peerstore.CertifiedAddrBook interface
+func (ps *privatePeerstore) ConsumePeerRecord(s *record.Envelope, ttl time.Duration) (bool, error) {
+	cab, ok := peerstore.GetCertifiedAddrBook(ps.Peerstore)
+	if !ok {
+		return false, nil
+	}
+
+	rec, err := s.Record()
+	if err != nil {
+		return false, err
+	}
+
+	peerRec, ok := rec.(*peer.PeerRecord)
+	if !ok {
+		return false, fmt.Errorf("unable to process envelope: not a PeerRecord")
+	}
+
+	if ps.isPrivate(peerRec.PeerID) {
+		return false, nil
+	}
+
+	return cab.ConsumePeerRecord(s, ttl)
+}
+
+// GetPeerRecord is part of the peerstore.CertifiedAddrBook interface
+func (ps *privatePeerstore) GetPeerRecord(p peer.ID) *record.Envelope {
+	cab, ok := peerstore.GetCertifiedAddrBook(ps.Peerstore)
+	if !ok || ps.isPrivate(p) {
+		return nil
+	}
+
+	return cab.GetPeerRecord(p)
+}
+
+func parsePeerIDs(peerStrs []string) ([]peer.ID, error) {
+	ids := make([]peer.ID, 0, len(peerStrs))
+	for _, peerStr := range peerStrs {
+		id, err := peer.Decode(peerStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID '%s': %w", peerStr, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// privatePeerRouting never looks up private peers, since a DHT query for a private peer would
+// reveal its ID to other peers. The node only connects to private peers when they dial it.
+type privatePeerRouting struct {
+	*dht.IpfsDHT
+	privatePS *privatePeerstore
+}
+
+// FindPeer is part of the routing.PeerRouting interface
+func (r *privatePeerRouting) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	if r.privatePS.isPrivate(id) {
+		return peer.AddrInfo{}, routing.ErrNotFound
+	}
+
+	return r.IpfsDHT.FindPeer(ctx, id)
+}
+
+// gossipDirectPeerOptions returns the gossipsub options for the direct peers and private peers.
+// Private peers are direct peers, so that gossipsub forwards every message to them immediately
+// and never adds them to the mesh.
+func gossipDirectPeerOptions(directPeers []peer.AddrInfo, privatePeers []peer.ID) []pubsub.Option {
+	peers := append([]peer.AddrInfo{}, directPeers...)
+	for _, id := range privatePeers {
+		peers = append(peers, peer.AddrInfo{ID: id})
+	}
+
+	if len(peers) == 0 {
+		return nil
+	}
+
+	return []pubsub.Option{pubsub.WithDirectPeers(peers)}
+}
+
+// Gossipsub offers direct peers through peer exchange like any other peer, and only leaves out
+// peers with a negative score. Private peers are given a negative score to keep them out of peer
+// exchange. Their score is well above the gossip and publish thresholds, and as direct peers they
+// are never in the mesh, so it does not change how messages reach them.
+const privatePeerScore = -1
+
+// privatePeerScoreOptions returns the gossipsub options that keep private peers out of peer exchange.
+// A node in sentry mode does not accept peer exchange from any peer.
+func privatePeerScoreOptions(privatePeers []peer.ID, sentryMode bool) []pubsub.Option {
+	if len(privatePeers) == 0 && !sentryMode {
+		return nil
+	}
+
+	private := make(map[peer.ID]bool)
+	for _, id := range privatePeers {
+		private[id] = true
+	}
+
+	params := &pubsub.PeerScoreParams{
+		AppSpecificScore: func(p peer.ID) float64 {
+			if private[p] {
+				return privatePeerScore
+			}
+			return 0
+		},
+		AppSpecificWeight: 1,
+		DecayInterval:     time.Second,
+		DecayToZero:       0.01,
+	}
+
+	thresholds := &pubsub.PeerScoreThresholds{
+		GossipThreshold:   -100,
+		PublishThreshold:  -200,
+		GraylistThreshold: -300,
+	}
+
+	if sentryMode {
+		// No peer scores this high, so peer exchange is always ignored
+		thresholds.AcceptPXThreshold = 1
+	}
+
+	return []pubsub.Option{pubsub.WithPeerScore(params, thresholds)}
+}
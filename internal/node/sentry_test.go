@@ -0,0 +1,201 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// pruneTracer records the peers offered through peer exchange in each prune sent by the router,
+// and the peers grafted to the mesh
+type pruneTracer struct {
+	mu     sync.Mutex
+	px     map[peer.ID][]peer.ID
+	grafts map[peer.ID]int
+}
+
+func newPruneTracer() *pruneTracer {
+	return &pruneTracer{px: make(map[peer.ID][]peer.ID), grafts: make(map[peer.ID]int)}
+}
+
+func (t *pruneTracer) SendRPC(rpc *pubsub.RPC, p peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, prune := range rpc.GetControl().GetPrune() {
+		for _, info := range prune.GetPeers() {
+			t.px[p] = append(t.px[p], peer.ID(info.PeerID))
+		}
+	}
+}
+
+func (t *pruneTracer) exchanged(p peer.ID) []peer.ID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]peer.ID(nil), t.px[p]...)
+}
+
+func (t *pruneTracer) Graft(p peer.ID, topic string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.grafts[p]++
+}
+
+func (t *pruneTracer) grafted(p peer.ID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.grafts[p] > 0
+}
+
+func (t *pruneTracer) AddPeer(p peer.ID, proto protocol.ID)        {}
+func (t *pruneTracer) RemovePeer(p peer.ID)                        {}
+func (t *pruneTracer) Join(topic string)                           {}
+func (t *pruneTracer) Leave(topic string)                          {}
+func (t *pruneTracer) Prune(p peer.ID, topic string)               {}
+func (t *pruneTracer) ValidateMessage(msg *pubsub.Message)         {}
+func (t *pruneTracer) DeliverMessage(msg *pubsub.Message)          {}
+func (t *pruneTracer) RejectMessage(msg *pubsub.Message, r string) {}
+func (t *pruneTracer) DuplicateMessage(msg *pubsub.Message)        {}
+func (t *pruneTracer) ThrottlePeer(p peer.ID)                      {}
+func (t *pruneTracer) RecvRPC(rpc *pubsub.RPC)                     {}
+func (t *pruneTracer) DropRPC(rpc *pubsub.RPC, p peer.ID)          {}
+func (t *pruneTracer) UndeliverableMessage(msg *pubsub.Message)    {}
+
+func newTestHost(t *testing.T, ctx context.Context) host.Host {
+	h, err := libp2p.New(ctx, libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func joinTestTopic(t *testing.T, ctx context.Context, h host.Host, opts ...pubsub.Option) (*pubsub.Topic, *pubsub.Subscription) {
+	ps, err := pubsub.NewGossipSub(ctx, h, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic, err := ps.Join("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return topic, sub
+}
+
+func TestPrivatePeerGossip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sentryHost := newTestHost(t, ctx)
+	defer sentryHost.Close()
+	privateHost := newTestHost(t, ctx)
+	defer privateHost.Close()
+
+	// More public peers than the sentry gossips to lazily, so the private peer only receives
+	// every message if it is forwarded directly
+	publicCount := pubsub.GossipSubDlazy + 3
+	publicHosts := make([]host.Host, 0, publicCount)
+	publicTopics := make([]*pubsub.Topic, 0, publicCount)
+	publicTracers := make([]*pruneTracer, 0, publicCount)
+	for i := 0; i < publicCount; i++ {
+		h := newTestHost(t, ctx)
+		defer h.Close()
+		tracer := newPruneTracer()
+		topic, _ := joinTestTopic(t, ctx, h, pubsub.WithPeerExchange(true), pubsub.WithRawTracer(tracer))
+		publicHosts = append(publicHosts, h)
+		publicTopics = append(publicTopics, topic)
+		publicTracers = append(publicTracers, tracer)
+	}
+
+	// The sentry and private peer are configured as the node configures them
+	tracer := newPruneTracer()
+	sentryOpts := []pubsub.Option{pubsub.WithPeerExchange(true), pubsub.WithRawTracer(tracer)}
+	sentryOpts = append(sentryOpts, privatePeerScoreOptions([]peer.ID{privateHost.ID()}, false)...)
+	sentryOpts = append(sentryOpts, gossipDirectPeerOptions(nil, []peer.ID{privateHost.ID()})...)
+	sentryTopic, sentrySub := joinTestTopic(t, ctx, sentryHost, sentryOpts...)
+
+	sentryInfo := peer.AddrInfo{ID: sentryHost.ID(), Addrs: sentryHost.Addrs()}
+	privateOpts := []pubsub.Option{pubsub.WithPeerExchange(true)}
+	privateOpts = append(privateOpts, privatePeerScoreOptions(nil, true)...)
+	privateOpts = append(privateOpts, gossipDirectPeerOptions([]peer.AddrInfo{sentryInfo}, nil)...)
+	_, privateSub := joinTestTopic(t, ctx, privateHost, privateOpts...)
+
+	for _, h := range append([]host.Host{privateHost}, publicHosts...) {
+		if err := h.Connect(ctx, sentryInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wait for the sentry to learn every subscription, and for each public peer to add the sentry to its mesh,
+	// since public peers only publish to their mesh
+	meshed := func() bool {
+		for _, publicTracer := range publicTracers {
+			if !publicTracer.grafted(sentryHost.ID()) {
+				return false
+			}
+		}
+		return len(sentryTopic.ListPeers()) == publicCount+1
+	}
+
+	for i := 0; i < 100 && !meshed(); i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if !meshed() {
+		t.Fatal("Public peers did not form a mesh with the sentry")
+	}
+
+	// Every message published by a public peer reaches the private peer without waiting for a heartbeat to gossip it
+	for i, topic := range publicTopics {
+		if err := topic.Publish(ctx, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+
+		recvCtx, recvCancel := context.WithTimeout(ctx, pubsub.GossipSubHeartbeatInterval/2)
+		_, err := privateSub.Next(recvCtx)
+		recvCancel()
+		if err != nil {
+			t.Fatalf("Private peer did not receive message %v: %v", i, err)
+		}
+	}
+
+	if tracer.grafted(privateHost.ID()) {
+		t.Error("Private peer was added to the sentry's mesh")
+	}
+
+	// Leaving the topic prunes the mesh with peer exchange
+	sentrySub.Cancel()
+
+	var exchanged []peer.ID
+	for i := 0; i < 100 && len(exchanged) == 0; i++ {
+		time.Sleep(time.Millisecond * 100)
+		for _, h := range publicHosts {
+			exchanged = append(exchanged, tracer.exchanged(h.ID())...)
+		}
+	}
+
+	if len(exchanged) == 0 {
+		t.Fatal("Expected public peers to be offered through peer exchange")
+	}
+
+	for _, id := range exchanged {
+		if id == privateHost.ID() {
+			t.Error("Private peer was offered through peer exchange")
+		}
+	}
+}
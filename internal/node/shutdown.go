@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+)
+
+// drainingRPC tracks in-flight block applications so that shutdown can wait for them to finish
+type drainingRPC struct {
+	rpc.LocalRPC
+
+	inflight sync.WaitGroup
+	draining bool
+	mutex    sync.Mutex
+}
+
+// ApplyBlock rpc call
+//
+// Block application is not interrupted by shutdown, so that a block is not abandoned while
+// the chain is applying it. It is still bounded by the deadline of the caller.
+func (r *drainingRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	r.mutex.Lock()
+	if r.draining {
+		r.mutex.Unlock()
+		return nil, p2perrors.ErrShuttingDown
+	}
+	r.inflight.Add(1)
+	r.mutex.Unlock()
+
+	defer r.inflight.Done()
+
+	applyCtx := context.Background()
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		applyCtx, cancel = context.WithDeadline(applyCtx, deadline)
+		defer cancel()
+	}
+
+	return r.LocalRPC.ApplyBlock(applyCtx, block)
+}
+
+// drain rejects new block applications and waits for in-flight ones to finish
+func (r *drainingRPC) drain(ctx context.Context) error {
+	r.mutex.Lock()
+	r.draining = true
+	r.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handle stops a started node
+type Handle struct {
+	node     *KoinosP2PNode
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	err      error
+}
+
+// Stop gracefully shuts down the node and closes it.
+//
+// Gossip and all peer connections are stopped, then in-flight block applications are given
+// until ctx is done to finish before bans are saved and the host is closed.
+func (h *Handle) Stop(ctx context.Context) error {
+	h.stopOnce.Do(func() {
+		h.err = h.node.stop(ctx, h.cancel)
+	})
+
+	return h.err
+}
+
+func (n *KoinosP2PNode) stop(ctx context.Context, cancel context.CancelFunc) error {
+	// Cancelling stops the gossip toggle before gossip is stopped, so it cannot be enabled again
+	cancel()
+	n.Gossip.StopGossip()
+
+	log.Info("Waiting for block application to finish...")
+	if err := n.applyTracker.drain(ctx); err != nil {
+		log.Warnf("Shutting down before block application finished: %s", err)
+	}
+
+	if n.banStateFile != "" {
+		if err := n.PeerErrorHandler.AccessList.Save(n.banStateFile); err != nil {
+			log.Warnf("Error saving bans: %s", err)
+		}
+	}
+
+	return n.Close()
+}
@@ -0,0 +1,19 @@
+//go:build !quic
+// +build !quic
+
+package node
+
+import (
+	"errors"
+
+	libp2p "github.com/libp2p/go-libp2p"
+)
+
+// ErrQUICUnsupported is when QUIC is enabled on a node built without the quic build tag
+var ErrQUICUnsupported = errors.New("QUIC transport is not supported by this build, rebuild with -tags quic")
+
+// The QUIC transport is behind a build tag because its quic-go release only builds with Go 1.15 to 1.17.
+// The release builds, CI and the Docker image all use the tag.
+func quicTransport() (libp2p.Option, error) {
+	return nil, ErrQUICUnsupported
+}
@@ -0,0 +1,22 @@
+//go:build !quic
+// +build !quic
+
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+)
+
+func TestQUICUnsupported(t *testing.T) {
+	config := options.NewConfig()
+	config.NodeOptions.EnableQUIC = true
+
+	_, err := NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/udp/8765/quic"}, NewTestRPC(128), nil, nil, "test1", config)
+	if !errors.Is(err, ErrQUICUnsupported) {
+		t.Errorf("Expected ErrQUICUnsupported, was %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build quic
+// +build quic
+
+package node
+
+import (
+	libp2p "github.com/libp2p/go-libp2p"
+	quic "github.com/libp2p/go-libp2p-quic-transport"
+)
+
+func quicTransport() (libp2p.Option, error) {
+	return libp2p.Transport(quic.NewTransport), nil
+}
@@ -0,0 +1,48 @@
+//go:build quic
+// +build quic
+
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+func TestQUICConnect(t *testing.T) {
+	ctx := context.Background()
+
+	config := options.NewConfig()
+	config.NodeOptions.EnableQUIC = true
+
+	bn, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/udp/8765/quic"}, NewTestRPC(128), nil, nil, "test1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bn.Close()
+
+	if !isListeningOn(bn, "/ip4/127.0.0.1/udp/8765/quic") {
+		t.Fatalf("Node should listen on QUIC, but listens on %v", bn.GetListenAddresses())
+	}
+
+	peerNode, err := NewKoinosP2PNode(ctx, []string{"/ip4/127.0.0.1/udp/8766/quic"}, NewTestRPC(128), nil, nil, "test2", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Close()
+
+	if err = peerNode.ConnectToPeerAddress(ctx, bn.GetAddressInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	conns := peerNode.Host.Network().ConnsToPeer(bn.Host.ID())
+	if len(conns) == 0 {
+		t.Fatal("Expected a connection to the peer")
+	}
+
+	if _, err = conns[0].RemoteMultiaddr().ValueForProtocol(multiaddr.P_QUIC); err != nil {
+		t.Errorf("Expected a QUIC connection, was %v", conns[0].RemoteMultiaddr())
+	}
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	tcp "github.com/libp2p/go-tcp-transport"
+	ws "github.com/libp2p/go-ws-transport"
+	multiaddr "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ErrNoTransports is when the node options do not enable any transport
+var ErrNoTransports = errors.New("no transports enabled")
+
+// transportOptions returns the libp2p options enabling only the configured transports
+func transportOptions(opts *options.NodeOptions) ([]libp2p.Option, error) {
+	transports := make([]libp2p.Option, 0)
+
+	if opts.EnableTCP {
+		transports = append(transports, libp2p.Transport(tcp.NewTCPTransport))
+	}
+
+	if opts.EnableQUIC {
+		quic, err := quicTransport()
+		if err != nil {
+			return nil, err
+		}
+		transports = append(transports, quic)
+	}
+
+	if opts.EnableWebSocket {
+		transports = append(transports, libp2p.Transport(ws.New))
+	}
+
+	if len(transports) == 0 {
+		return nil, ErrNoTransports
+	}
+
+	return transports, nil
+}
+
+// addressFilter decides which of the host's addresses are announced to peers
+type addressFilter struct {
+	announce         []multiaddr.Multiaddr
+	external         []multiaddr.Multiaddr
+	noAnnounce       []multiaddr.Multiaddr
+	noAnnounceRanges []*net.IPNet
+	hidePrivate      bool
+}
+
+func newAddressFilter(opts *options.NodeOptions) (*addressFilter, error) {
+	f := &addressFilter{hidePrivate: opts.HidePrivateAddresses}
+
+	for _, addrStr := range opts.AnnounceAddresses {
+		addr, err := multiaddr.NewMultiaddr(addrStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid announce address '%s': %w", addrStr, err)
+		}
+		f.announce = append(f.announce, addr)
+	}
+
+	for _, addrStr := range opts.ExternalAddresses {
+		addr, err := multiaddr.NewMultiaddr(addrStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external address '%s': %w", addrStr, err)
+		}
+		f.external = append(f.external, addr)
+	}
+
+	for _, addrStr := range opts.NoAnnounceAddresses {
+		if _, subnet, err := net.ParseCIDR(addrStr); err == nil {
+			f.noAnnounceRanges = append(f.noAnnounceRanges, subnet)
+			continue
+		}
+
+		addr, err := multiaddr.NewMultiaddr(addrStr)
+		if err != nil {
+			return nil, fmt.Errorf("no-announce address '%s' is not a multiaddress or CIDR range", addrStr)
+		}
+		f.noAnnounce = append(f.noAnnounce, addr)
+	}
+
+	return f, nil
+}
+
+// filter is used as the host's address factory
+func (f *addressFilter) filter(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	if len(f.announce) > 0 {
+		addrs = f.announce
+	}
+
+	filtered := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if !f.isHidden(addr) {
+			filtered = append(filtered, addr)
+		}
+	}
+
+	// External addresses are configured explicitly, so they are never hidden
+	for _, addr := range f.external {
+		if !containsAddr(filtered, addr) {
+			filtered = append(filtered, addr)
+		}
+	}
+
+	return filtered
+}
+
+func (f *addressFilter) isHidden(addr multiaddr.Multiaddr) bool {
+	if containsAddr(f.noAnnounce, addr) {
+		return true
+	}
+
+	// Addresses without an IP (e.g. DNS addresses) can only be hidden explicitly
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return false
+	}
+
+	for _, subnet := range f.noAnnounceRanges {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return f.hidePrivate && (manet.IsPrivateAddr(addr) || ip.IsLinkLocalUnicast())
+}
+
+func containsAddr(addrs []multiaddr.Multiaddr, addr multiaddr.Multiaddr) bool {
+	for _, a := range addrs {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
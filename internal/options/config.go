@@ -6,6 +6,10 @@ type Config struct {
 	PeerConnectionOptions   PeerConnectionOptions
 	PeerErrorHandlerOptions PeerErrorHandlerOptions
 	GossipToggleOptions     GossipToggleOptions
+	GossipOptions           GossipOptions
+	OrphanBlockPoolOptions  OrphanBlockPoolOptions
+	HealthOptions           HealthOptions
+	SyncProgressOptions     SyncProgressOptions
 }
 
 // NewConfig creates a new Config
@@ -15,6 +19,10 @@ func NewConfig() *Config {
 		PeerConnectionOptions:   *NewPeerConnectionOptions(),
 		PeerErrorHandlerOptions: *NewPeerErrorHandlerOptions(),
 		GossipToggleOptions:     *NewGossipToggleOptions(),
+		GossipOptions:           *NewGossipOptions(),
+		OrphanBlockPoolOptions:  *NewOrphanBlockPoolOptions(),
+		HealthOptions:           *NewHealthOptions(),
+		SyncProgressOptions:     *NewSyncProgressOptions(),
 	}
 	return &config
 }
@@ -1,69 +1,147 @@
 package options
 
 import (
-	"math"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// BanEntry is a manually banned peer ID or IP/CIDR address range
+type BanEntry struct {
+	Target     string
+	Expiration time.Time // Zero for a permanent ban
+	Reason     string
+}
+
+// ParseBanEntry parses a ban entry in the form target[|expiration[|reason]],
+// where expiration is an RFC 3339 timestamp or a duration from now
+func ParseBanEntry(entry string) (BanEntry, error) {
+	parts := strings.SplitN(entry, "|", 3)
+	ban := BanEntry{Target: strings.TrimSpace(parts[0])}
+
+	if ban.Target == "" {
+		return ban, fmt.Errorf("ban entry '%s' is missing a target", entry)
+	}
+
+	if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+		expiration := strings.TrimSpace(parts[1])
+		if t, err := time.Parse(time.RFC3339, expiration); err == nil {
+			ban.Expiration = t
+		} else if d, err := time.ParseDuration(expiration); err == nil {
+			ban.Expiration = time.Now().Add(d)
+		} else {
+			return ban, fmt.Errorf("could not parse ban expiration '%s'", expiration)
+		}
+	}
+
+	if len(parts) > 2 {
+		ban.Reason = strings.TrimSpace(parts[2])
+	}
+
+	return ban, nil
+}
+
 const (
 	errorScoreDecayHalflifeDefault = time.Minute * 10
 	errorScoreThresholdDefault     = 100000
+	ipErrorScoreThresholdDefault   = errorScoreThresholdDefault
 
-	deserializationErrorScoreDefault        = 5000
-	serializationErrorScoreDefault          = 0
-	blockIrreversibilityErrorScoreDefault   = 100
-	blockApplicationErrorScoreDefault       = 5000
-	transactionApplicationErrorScoreDefault = 1000
-	chainIDMismatchErrorScoreDefault        = uint64(math.MaxUint32)
-	chainNotConnectedErrorScoreDefault      = uint64(math.MaxUint32)
-	checkpointMismatchErrorScoreDefault     = uint64(math.MaxUint32)
-	localRPCErrorScoreDefault               = 0
-	peerRPCErrorScoreDefault                = 1000
-	localRPCTimeoutErrorScoreDefault        = 0
-	peerRPCTimeoutErrorScoreDefault         = 1000
-	processRequestTimeoutErrorScoreDefault  = 0
-	unknownErrorScoreDefault                = blockApplicationErrorScoreDefault
+	reputationDecayHalflifeDefault = time.Hour
+	maxReputationDefault           = errorScoreThresholdDefault
+	maxReputationOffsetDefault     = errorScoreThresholdDefault / 2
+	syncBlockReputationDefault     = 1
+	gossipBlockReputationDefault   = 10
+	lowLatencyReputationDefault    = 10
+
+	banDurationDefault          = time.Minute * 10
+	maxBanDurationDefault       = time.Hour * 24
+	banEscalationFactorDefault  = 4
+	banOffenseExpirationDefault = time.Hour * 24
+
+	errorHistorySizeDefault     = 32
+	errorHistoryMaxPeersDefault = 1024
+
+	maxConnectionsPerIPDefault     = 8
+	maxConnectionsPerSubnetDefault = 32
+	ipv4SubnetMaskBitsDefault      = 24
+	ipv6SubnetMaskBitsDefault      = 48
 )
 
 // PeerErrorHandlerOptions are options for PeerErrorHandler
 type PeerErrorHandlerOptions struct {
 	ErrorScoreDecayHalflife time.Duration
 	ErrorScoreThreshold     uint64
+	IPErrorScoreThreshold   uint64
+
+	// Reputation credited for useful behavior, which offsets the error score up to MaxReputationOffset
+	ReputationDecayHalflife time.Duration
+	MaxReputation           uint64
+	MaxReputationOffset     uint64
+	SyncBlockReputation     uint64
+	GossipBlockReputation   uint64
+	LowLatencyReputation    uint64
+
+	// Peers exceeding an error threshold are banned for BanDuration, multiplied by BanEscalationFactor for each
+	// repeated offense within BanOffenseExpiration, up to MaxBanDuration. Errors in PermanentBanCategories
+	// result in a permanent ban.
+	BanDuration            time.Duration
+	MaxBanDuration         time.Duration
+	BanEscalationFactor    uint64
+	BanOffenseExpiration   time.Duration
+	PermanentBanCategories []ErrorCategory
+
+	// Number of recent errors kept per peer, and the number of peers for which they are kept
+	ErrorHistorySize     uint64
+	ErrorHistoryMaxPeers uint64
+
+	// Limits on inbound connections from a single IP address or subnet, 0 for no limit
+	MaxConnectionsPerIP     uint64
+	MaxConnectionsPerSubnet uint64
+	IPv4SubnetMaskBits      uint64
+	IPv6SubnetMaskBits      uint64
+
+	// Score, half-life and threshold for each category of peer error
+	ErrorScores map[ErrorCategory]ErrorScoreOptions
+
+	// Peers and addresses which are never allowed to connect
+	BanList []BanEntry
+
+	// Peers which are never gated
+	AllowList []string
+
+	// Only peers on the allow list can connect
+	AllowOnly bool
 
-	DeserializationErrorScore        uint64
-	SerializationErrorScore          uint64
-	BlockIrreversibilityErrorScore   uint64
-	BlockApplicationErrorScore       uint64
-	TransactionApplicationErrorScore uint64
-	ChainIDMismatchErrorScore        uint64
-	ChainNotConnectedErrorScore      uint64
-	CheckpointMismatchErrorScore     uint64
-	LocalRPCErrorScore               uint64
-	PeerRPCErrorScore                uint64
-	LocalRPCTimeoutErrorScore        uint64
-	PeerRPCTimeoutErrorScore         uint64
-	ProcessRequestTimeoutErrorScore  uint64
-	UnknownErrorScore                uint64
+	// File in which bans are saved on shutdown and restored on startup, empty to not persist bans
+	BanStateFile string
 }
 
 // NewPeerErrorHandlerOptions returns default initialized PeerErrorHandlerOptions
 func NewPeerErrorHandlerOptions() *PeerErrorHandlerOptions {
 	return &PeerErrorHandlerOptions{
-		ErrorScoreDecayHalflife:          errorScoreDecayHalflifeDefault,
-		ErrorScoreThreshold:              errorScoreThresholdDefault,
-		DeserializationErrorScore:        deserializationErrorScoreDefault,
-		SerializationErrorScore:          serializationErrorScoreDefault,
-		BlockIrreversibilityErrorScore:   blockIrreversibilityErrorScoreDefault,
-		BlockApplicationErrorScore:       blockApplicationErrorScoreDefault,
-		TransactionApplicationErrorScore: transactionApplicationErrorScoreDefault,
-		ChainIDMismatchErrorScore:        chainIDMismatchErrorScoreDefault,
-		ChainNotConnectedErrorScore:      chainNotConnectedErrorScoreDefault,
-		CheckpointMismatchErrorScore:     checkpointMismatchErrorScoreDefault,
-		LocalRPCErrorScore:               localRPCErrorScoreDefault,
-		PeerRPCErrorScore:                peerRPCErrorScoreDefault,
-		LocalRPCTimeoutErrorScore:        localRPCTimeoutErrorScoreDefault,
-		PeerRPCTimeoutErrorScore:         peerRPCTimeoutErrorScoreDefault,
-		ProcessRequestTimeoutErrorScore:  processRequestTimeoutErrorScoreDefault,
-		UnknownErrorScore:                unknownErrorScoreDefault,
+		ErrorScoreDecayHalflife: errorScoreDecayHalflifeDefault,
+		ErrorScoreThreshold:     errorScoreThresholdDefault,
+		IPErrorScoreThreshold:   ipErrorScoreThresholdDefault,
+		ReputationDecayHalflife: reputationDecayHalflifeDefault,
+		MaxReputation:           maxReputationDefault,
+		MaxReputationOffset:     maxReputationOffsetDefault,
+		SyncBlockReputation:     syncBlockReputationDefault,
+		GossipBlockReputation:   gossipBlockReputationDefault,
+		LowLatencyReputation:    lowLatencyReputationDefault,
+		BanDuration:             banDurationDefault,
+		MaxBanDuration:          maxBanDurationDefault,
+		BanEscalationFactor:     banEscalationFactorDefault,
+		BanOffenseExpiration:    banOffenseExpirationDefault,
+		PermanentBanCategories:  []ErrorCategory{ChainIDMismatchError},
+		ErrorHistorySize:        errorHistorySizeDefault,
+		ErrorHistoryMaxPeers:    errorHistoryMaxPeersDefault,
+		MaxConnectionsPerIP:     maxConnectionsPerIPDefault,
+		MaxConnectionsPerSubnet: maxConnectionsPerSubnetDefault,
+		IPv4SubnetMaskBits:      ipv4SubnetMaskBitsDefault,
+		IPv6SubnetMaskBits:      ipv6SubnetMaskBitsDefault,
+		ErrorScores:             NewErrorScores(),
+		BanList:                 make([]BanEntry, 0),
+		AllowList:               make([]string, 0),
+		AllowOnly:               false,
 	}
 }
@@ -0,0 +1,169 @@
+package options
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrorCategory identifies a category of peer error for scoring
+type ErrorCategory string
+
+// Peer error categories
+const (
+	DeserializationError         ErrorCategory = "deserialization"
+	SerializationError           ErrorCategory = "serialization"
+	BlockIrreversibilityError    ErrorCategory = "block-irreversibility"
+	BlockApplicationError        ErrorCategory = "block-application"
+	UnknownPreviousBlockError    ErrorCategory = "unknown-previous-block"
+	InvalidBlockSignatureError   ErrorCategory = "invalid-block-signature"
+	DuplicateBlockError          ErrorCategory = "duplicate-block"
+	InvalidBlockTransactionError ErrorCategory = "invalid-block-transaction"
+	BlockResourceLimitError      ErrorCategory = "block-resource-limit"
+	TransactionApplicationError  ErrorCategory = "transaction-application"
+	TransactionRateLimitError    ErrorCategory = "transaction-rate-limit"
+	ChainIDMismatchError         ErrorCategory = "chain-id-mismatch"
+	ChainNotConnectedError       ErrorCategory = "chain-not-connected"
+	CheckpointMismatchError      ErrorCategory = "checkpoint-mismatch"
+	LocalRPCError                ErrorCategory = "local-rpc"
+	PeerRPCError                 ErrorCategory = "peer-rpc"
+	LocalRPCTimeoutError         ErrorCategory = "local-rpc-timeout"
+	PeerRPCTimeoutError          ErrorCategory = "peer-rpc-timeout"
+	ProcessRequestTimeoutError   ErrorCategory = "process-request-timeout"
+	UnknownError                 ErrorCategory = "unknown"
+)
+
+// ErrorScoreOptions are the scoring options for a category of peer error
+type ErrorScoreOptions struct {
+	// Score added for each error in the category
+	Score uint64
+
+	// Half-life of the category score, 0 to use ErrorScoreDecayHalflife
+	Halflife time.Duration
+
+	// Disconnect when the category score alone reaches the threshold, 0 for no category threshold
+	Threshold uint64
+}
+
+const (
+	transientErrorHalflifeDefault = time.Minute
+	maliciousErrorHalflifeDefault = time.Hour
+)
+
+// NewErrorScores returns the default scoring table for all error categories
+func NewErrorScores() map[ErrorCategory]ErrorScoreOptions {
+	return map[ErrorCategory]ErrorScoreOptions{
+		DeserializationError:         {Score: 5000, Halflife: maliciousErrorHalflifeDefault},
+		SerializationError:           {Score: 0},
+		BlockIrreversibilityError:    {Score: 100},
+		BlockApplicationError:        {Score: 5000},
+		UnknownPreviousBlockError:    {Score: 100},
+		InvalidBlockSignatureError:   {Score: errorScoreThresholdDefault, Halflife: maliciousErrorHalflifeDefault},
+		DuplicateBlockError:          {Score: 0},
+		InvalidBlockTransactionError: {Score: 10000, Halflife: maliciousErrorHalflifeDefault},
+		BlockResourceLimitError:      {Score: 5000},
+		TransactionApplicationError:  {Score: 1000},
+		TransactionRateLimitError:    {Score: 1000, Halflife: transientErrorHalflifeDefault},
+		ChainIDMismatchError:         {Score: uint64(math.MaxUint32)},
+		ChainNotConnectedError:       {Score: uint64(math.MaxUint32)},
+		CheckpointMismatchError:      {Score: uint64(math.MaxUint32)},
+		LocalRPCError:                {Score: 0},
+		PeerRPCError:                 {Score: 1000},
+		LocalRPCTimeoutError:         {Score: 0},
+		PeerRPCTimeoutError:          {Score: 1000, Halflife: transientErrorHalflifeDefault},
+		ProcessRequestTimeoutError:   {Score: 0},
+		UnknownError:                 {Score: 5000},
+	}
+}
+
+// ParseErrorScores overrides entries of the scoring table from a config value in the form
+//
+//	category:
+//	  score: 1000
+//	  halflife: 1m
+//	  threshold: 3000
+//
+// Fields which are not specified keep their current values. The whole table is validated before any entry
+// is overridden, so scores are unchanged when an error is returned.
+func ParseErrorScores(value interface{}, scores map[ErrorCategory]ErrorScoreOptions) error {
+	categories, err := toStringMap(value)
+	if err != nil {
+		return fmt.Errorf("error scores %w", err)
+	}
+
+	parsed := make(map[ErrorCategory]ErrorScoreOptions, len(categories))
+	for name, fields := range categories {
+		category := ErrorCategory(name)
+		entry, ok := scores[category]
+		if !ok {
+			return fmt.Errorf("unknown error category '%s'", name)
+		}
+
+		values, err := toStringMap(fields)
+		if err != nil {
+			return fmt.Errorf("error category '%s' %w", name, err)
+		}
+
+		for field, v := range values {
+			switch field {
+			case "score":
+				entry.Score, err = toUint64(v)
+			case "threshold":
+				entry.Threshold, err = toUint64(v)
+			case "halflife":
+				entry.Halflife, err = time.ParseDuration(fmt.Sprint(v))
+				if err == nil && entry.Halflife < 0 {
+					err = fmt.Errorf("halflife %v must not be negative", entry.Halflife)
+				}
+			default:
+				err = fmt.Errorf("unknown field '%s'", field)
+			}
+
+			if err != nil {
+				return fmt.Errorf("error category '%s': %w", name, err)
+			}
+		}
+
+		parsed[category] = entry
+	}
+
+	for category, entry := range parsed {
+		scores[category] = entry
+	}
+
+	return nil
+}
+
+func toStringMap(value interface{}) (map[string]interface{}, error) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			result[fmt.Sprint(k)] = v
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("must be a map, was %T", value)
+	}
+}
+
+func toUint64(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("value %v must not be negative", v)
+		}
+		return uint64(v), nil
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("value %v must not be negative", v)
+		}
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("value %v must be an integer", value)
+	}
+}
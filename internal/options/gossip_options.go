@@ -0,0 +1,59 @@
+package options
+
+import (
+	"time"
+)
+
+const (
+	missingBlockFetchDepthDefault    = 20
+	missingBlockFetchTimeoutDefault  = time.Second
+	enableTransactionBatchingDefault = false
+	transactionBatchMaxSizeDefault   = 100
+	transactionBatchMaxBytesDefault  = 512 * 1024
+	transactionBatchMaxDelayDefault  = time.Millisecond * 100
+	transactionRateLimitDefault      = 500
+	transactionRateBurstDefault      = 2500
+)
+
+// MaxTransactionBatchBytes is the largest allowed TransactionBatchMaxBytes. It keeps a batch under
+// the pubsub message size limit of 1 MiB, with room for the message envelope.
+const MaxTransactionBatchBytes = 960 * 1024
+
+// GossipOptions are options for KoinosGossip
+type GossipOptions struct {
+	MissingBlockFetchDepth   uint64
+	MissingBlockFetchTimeout time.Duration
+
+	// Publish transactions produced by this node in batches on the transaction batch topic instead of one
+	// message each. Batches from peers are always accepted.
+	EnableTransactionBatching bool
+
+	// A batch is published once it holds this many transactions
+	TransactionBatchMaxSize int
+
+	// A batch is published before it grows beyond this many bytes, a larger transaction is published alone
+	TransactionBatchMaxBytes int
+
+	// A batch is published once its oldest transaction has waited this long
+	TransactionBatchMaxDelay time.Duration
+
+	// Transactions per second accepted via gossip from each peer, 0 for no limit
+	TransactionRateLimit float64
+
+	// Transactions a peer may send at once before the rate limit applies
+	TransactionRateBurst uint64
+}
+
+// NewGossipOptions returns default initialized GossipOptions
+func NewGossipOptions() *GossipOptions {
+	return &GossipOptions{
+		MissingBlockFetchDepth:    missingBlockFetchDepthDefault,
+		MissingBlockFetchTimeout:  missingBlockFetchTimeoutDefault,
+		EnableTransactionBatching: enableTransactionBatchingDefault,
+		TransactionBatchMaxSize:   transactionBatchMaxSizeDefault,
+		TransactionBatchMaxBytes:  transactionBatchMaxBytesDefault,
+		TransactionBatchMaxDelay:  transactionBatchMaxDelayDefault,
+		TransactionRateLimit:      transactionRateLimitDefault,
+		TransactionRateBurst:      transactionRateBurstDefault,
+	}
+}
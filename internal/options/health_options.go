@@ -0,0 +1,31 @@
+package options
+
+import (
+	"time"
+)
+
+const (
+	healthListenAddressDefault = ""
+	stallDurationDefault       = time.Minute * 2
+	healthCheckTimeoutDefault  = time.Second
+)
+
+// HealthOptions are options for the health check and metrics endpoints
+type HealthOptions struct {
+	// Address on which to serve the HTTP health and metrics endpoints, empty to disable
+	ListenAddress string
+
+	// The node is considered stalled if its head has not advanced for this long while behind its peers
+	StallDuration time.Duration
+
+	CheckTimeout time.Duration
+}
+
+// NewHealthOptions returns default initialized HealthOptions
+func NewHealthOptions() *HealthOptions {
+	return &HealthOptions{
+		ListenAddress: healthListenAddressDefault,
+		StallDuration: stallDurationDefault,
+		CheckTimeout:  healthCheckTimeoutDefault,
+	}
+}
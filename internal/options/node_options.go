@@ -1,22 +1,122 @@
 package options
 
+import (
+	"time"
+)
+
+// Reachability values that override AutoNAT detection
+const (
+	ReachabilityPublic  = "public"
+	ReachabilityPrivate = "private"
+)
+
+const (
+	localServiceCheckIntervalDefault = time.Second * 5
+	localServiceCheckTimeoutDefault  = time.Second * 3
+	localServiceMaxBackoffDefault    = time.Second * 30
+)
+
 // NodeOptions is options that affect the whole node
 type NodeOptions struct {
 	// Peers to initially connect
 	InitialPeers []string
 
-	// Peers to directly connect
+	// Peers to directly connect. Gossipsub always forwards messages to direct peers and keeps reconnecting to them.
+	// Direct peers should be reciprocal.
 	DirectPeers []string
 
+	// Submit newly accepted blocks to the connected direct peers through the peer rpc, in parallel with gossip
+	EnableDirectBlockPush bool
+
 	// Force gossip mode on startup
 	ForceGossip bool
+
+	// How often the chain and block store are checked while they are reachable
+	LocalServiceCheckInterval time.Duration
+
+	LocalServiceCheckTimeout time.Duration
+
+	// While the chain or block store is unreachable, checks back off exponentially up to this interval
+	LocalServiceMaxBackoff time.Duration
+
+	// Transports the host listens and dials on. At least one must be enabled.
+	EnableTCP       bool
+	EnableQUIC      bool
+	EnableWebSocket bool
+
+	// Multiaddresses announced to peers in place of the listen addresses (e.g. a public address behind a load balancer)
+	AnnounceAddresses []string
+
+	// Multiaddresses or CIDR ranges that are never announced to peers
+	NoAnnounceAddresses []string
+
+	// Do not announce private, loopback, and link local addresses
+	HidePrivateAddresses bool
+
+	// File containing the pre-shared key of a private network. Only nodes with the same key can connect.
+	// The node joins the public network if empty.
+	PrivateNetworkKeyFile string
+
+	// Attempt to open ports using UPnP and NAT-PMP for NATed hosts
+	EnableNATPortMap bool
+
+	// Accept connections through relays and dial peers through relays when asked to
+	EnableRelay bool
+
+	// Find relays and announce relay addresses when AutoNAT detects the node is not reachable
+	EnableAutoRelay bool
+
+	// Relay traffic on behalf of other peers and advertise as a relay. Requires EnableRelay.
+	EnableRelayHop bool
+
+	// Relay multiaddresses, including the peer ID, used by auto relay instead of relays found through the DHT
+	StaticRelays []string
+
+	// Help other peers determine if they are reachable by dialing them back
+	EnableNATService bool
+
+	// ReachabilityPublic or ReachabilityPrivate overrides AutoNAT detection. It is detected if empty.
+	ForceReachability string
+
+	// Multiaddresses announced to peers in addition to the listen addresses (e.g. a public address forwarded to the node)
+	ExternalAddresses []string
+
+	// Only connect to the initial peers, which are the node's sentries, and allowed peers.
+	// The sentries are gossipsub direct peers. The node ignores peers offered through gossipsub
+	// peer exchange and only queries the DHT.
+	SentryMode bool
+
+	// IDs of peers whose addresses are never shared with other peers, such as block producers behind this node.
+	// Private peers must dial this node. They are gossipsub direct peers, so they receive every message without delay.
+	PrivatePeers []string
 }
 
 // NewNodeOptions creates a NodeOptions object which controls how p2p works
 func NewNodeOptions() *NodeOptions {
 	return &NodeOptions{
-		InitialPeers: make([]string, 0),
-		DirectPeers:  make([]string, 0),
-		ForceGossip:  false,
+		InitialPeers:              make([]string, 0),
+		DirectPeers:               make([]string, 0),
+		EnableDirectBlockPush:     false,
+		ForceGossip:               false,
+		LocalServiceCheckInterval: localServiceCheckIntervalDefault,
+		LocalServiceCheckTimeout:  localServiceCheckTimeoutDefault,
+		LocalServiceMaxBackoff:    localServiceMaxBackoffDefault,
+		EnableTCP:                 true,
+		EnableQUIC:                false,
+		EnableWebSocket:           true,
+		AnnounceAddresses:         make([]string, 0),
+		NoAnnounceAddresses:       make([]string, 0),
+		HidePrivateAddresses:      false,
+		PrivateNetworkKeyFile:     "",
+		EnableNATPortMap:          true,
+		EnableRelay:               true,
+		EnableAutoRelay:           true,
+		EnableRelayHop:            false,
+		StaticRelays:              make([]string, 0),
+		EnableNATService:          true,
+		ForceReachability:         "",
+		ExternalAddresses:         make([]string, 0),
+		SentryMode:                false,
+		PrivatePeers:              make([]string, 0),
 	}
 }
@@ -0,0 +1,27 @@
+package options
+
+import (
+	"time"
+)
+
+const (
+	orphanBlockMaxCountDefault   = 128
+	orphanBlockMaxBytesDefault   = 32 * 1024 * 1024
+	orphanBlockExpirationDefault = time.Minute * 2
+)
+
+// OrphanBlockPoolOptions are options for OrphanBlockPool
+type OrphanBlockPoolOptions struct {
+	MaxCount   uint64
+	MaxBytes   uint64
+	Expiration time.Duration
+}
+
+// NewOrphanBlockPoolOptions returns default initialized OrphanBlockPoolOptions
+func NewOrphanBlockPoolOptions() *OrphanBlockPoolOptions {
+	return &OrphanBlockPoolOptions{
+		MaxCount:   orphanBlockMaxCountDefault,
+		MaxBytes:   orphanBlockMaxBytesDefault,
+		Expiration: orphanBlockExpirationDefault,
+	}
+}
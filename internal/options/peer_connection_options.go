@@ -20,6 +20,27 @@ const (
 	handshakeRetryTimeDefault    = time.Second * 3
 	syncedBlockDeltaDefault      = 5
 	syncedPingTimeDefault        = time.Second * 10
+	lowLatencyThresholdDefault   = time.Millisecond * 250
+	maxPeersDefault              = 0
+	blockSyncPeersDefault        = 8
+	pingIntervalDefault          = time.Second * 15
+	pingTimeoutDefault           = time.Second * 5
+	rttSmoothingFactorDefault    = 0.2
+	rttTimeoutMultiplierDefault  = 4
+
+	blockRequestMinBatchSizeDefault        = 10
+	blockRequestMaxBatchSizeDefault        = 10000
+	blockRequestBatchIncreaseDefault       = 100
+	blockRequestBatchDecreaseFactorDefault = 0.5
+	blockRequestTimeoutMarginDefault       = 3
+	blockRequestMaxTimeoutDefault          = time.Minute
+	blockThroughputSmoothingFactorDefault  = 0.2
+
+	pendingTransactionsSyncPeersDefault = 3
+	pendingTransactionsPageSizeDefault  = 100
+	pendingTransactionsMaxBytesDefault  = 1024 * 1024
+	pendingTransactionsMaxCountDefault  = 10000
+	applyTransactionTimeoutDefault      = time.Second
 )
 
 // PeerConnectionOptions are options for PeerConnection
@@ -27,11 +48,43 @@ type PeerConnectionOptions struct {
 	Checkpoints           []Checkpoint
 	LocalRPCTimeout       time.Duration
 	RemoteRPCTimeout      time.Duration
-	BlockRequestBatchSize uint64
-	BlockRequestTimeout   time.Duration
+	BlockRequestBatchSize uint64        // Initial batch size for each peer
+	BlockRequestTimeout   time.Duration // Minimum timeout of a block request
 	HandshakeRetryTime    time.Duration
 	SyncedBlockDelta      uint64
 	SyncedPingTime        time.Duration
+	LowLatencyThreshold   time.Duration
+	MaxPeers              uint64 // Over this many peers, the lowest reputation peer is disconnected. 0 for no limit.
+	BlockSyncPeers        uint64 // Blocks are only requested from this many peers, highest reputation first. 0 for all peers.
+
+	// Connected peers are pinged every PingInterval. Their round trip time is an exponentially weighted
+	// moving average, where RTTSmoothingFactor is the weight of the newest ping.
+	PingInterval       time.Duration
+	PingTimeout        time.Duration
+	RTTSmoothingFactor float64
+
+	// Remote rpc timeouts are extended by this multiple of the peer's round trip time
+	RTTTimeoutMultiplier uint64
+
+	// The block request batch size of each peer grows by BlockRequestBatchIncrease after every full batch and
+	// shrinks by BlockRequestBatchDecreaseFactor after every timeout, within the min and max batch sizes.
+	// Once the peer's throughput is known, the timeout is BlockRequestTimeoutMargin times the expected
+	// duration of the batch, but never less than BlockRequestTimeout or more than BlockRequestMaxTimeout.
+	// The throughput is an exponentially weighted moving average, where BlockThroughputSmoothingFactor is
+	// the weight of the newest batch.
+	BlockRequestMinBatchSize        uint64
+	BlockRequestMaxBatchSize        uint64
+	BlockRequestBatchIncrease       uint64
+	BlockRequestBatchDecreaseFactor float64
+	BlockRequestTimeoutMargin       float64
+	BlockRequestMaxTimeout          time.Duration
+	BlockThroughputSmoothingFactor  float64
+
+	PendingTransactionsSyncPeers uint64
+	PendingTransactionsPageSize  uint64
+	PendingTransactionsMaxBytes  uint64
+	PendingTransactionsMaxCount  uint64
+	ApplyTransactionTimeout      time.Duration
 }
 
 // NewPeerConnectionOptions returns default initialized PeerConnectionOptions
@@ -45,5 +98,26 @@ func NewPeerConnectionOptions() *PeerConnectionOptions {
 		HandshakeRetryTime:    handshakeRetryTimeDefault,
 		SyncedBlockDelta:      syncedBlockDeltaDefault,
 		SyncedPingTime:        syncedPingTimeDefault,
+		LowLatencyThreshold:   lowLatencyThresholdDefault,
+		MaxPeers:              maxPeersDefault,
+		BlockSyncPeers:        blockSyncPeersDefault,
+		PingInterval:          pingIntervalDefault,
+		PingTimeout:           pingTimeoutDefault,
+		RTTSmoothingFactor:    rttSmoothingFactorDefault,
+		RTTTimeoutMultiplier:  rttTimeoutMultiplierDefault,
+
+		BlockRequestMinBatchSize:        blockRequestMinBatchSizeDefault,
+		BlockRequestMaxBatchSize:        blockRequestMaxBatchSizeDefault,
+		BlockRequestBatchIncrease:       blockRequestBatchIncreaseDefault,
+		BlockRequestBatchDecreaseFactor: blockRequestBatchDecreaseFactorDefault,
+		BlockRequestTimeoutMargin:       blockRequestTimeoutMarginDefault,
+		BlockRequestMaxTimeout:          blockRequestMaxTimeoutDefault,
+		BlockThroughputSmoothingFactor:  blockThroughputSmoothingFactorDefault,
+
+		PendingTransactionsSyncPeers: pendingTransactionsSyncPeersDefault,
+		PendingTransactionsPageSize:  pendingTransactionsPageSizeDefault,
+		PendingTransactionsMaxBytes:  pendingTransactionsMaxBytesDefault,
+		PendingTransactionsMaxCount:  pendingTransactionsMaxCountDefault,
+		ApplyTransactionTimeout:      applyTransactionTimeoutDefault,
 	}
 }
@@ -0,0 +1,31 @@
+package options
+
+import (
+	"time"
+)
+
+const (
+	syncProgressReportIntervalDefault = time.Second * 10
+	syncProgressPeerExpirationDefault = time.Minute
+)
+
+// SyncProgressOptions are options for SyncProgress
+type SyncProgressOptions struct {
+	// Progress is logged and broadcast every ReportInterval
+	ReportInterval time.Duration
+
+	// Peers that have not reported for PeerExpiration no longer count toward the best peer head
+	PeerExpiration time.Duration
+
+	// The node is syncing while it is more than SyncedBlockDelta blocks behind the best peer head.
+	// Set from PeerConnectionOptions by the node.
+	SyncedBlockDelta uint64
+}
+
+// NewSyncProgressOptions returns default initialized SyncProgressOptions
+func NewSyncProgressOptions() *SyncProgressOptions {
+	return &SyncProgressOptions{
+		ReportInterval: syncProgressReportIntervalDefault,
+		PeerExpiration: syncProgressPeerExpirationDefault,
+	}
+}
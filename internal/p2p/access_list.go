@@ -0,0 +1,327 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Ban represents a manual ban of a peer or address range
+type Ban struct {
+	Peer       peer.ID
+	Subnet     *net.IPNet
+	Expiration time.Time // Zero for a permanent ban
+	Reason     string
+	Offenses   uint64 // Number of escalating offenses for automatic bans, zero for manual bans
+}
+
+// IsExpired returns if the ban has expired
+func (b *Ban) IsExpired(now time.Time) bool {
+	return !b.Expiration.IsZero() && !now.Before(b.Expiration)
+}
+
+// Target returns a string representation of what the ban applies to
+func (b *Ban) Target() string {
+	if b.Subnet != nil {
+		return b.Subnet.String()
+	}
+
+	return b.Peer.Pretty()
+}
+
+// AccessList tracks manually banned peers and addresses and peers which are always allowed
+type AccessList struct {
+	peerBans     map[peer.ID]*Ban
+	subnetBans   map[string]*Ban
+	allowedPeers map[peer.ID]bool
+	mutex        sync.RWMutex
+}
+
+// NewAccessList creates an AccessList populated from the given options
+func NewAccessList(opts options.PeerErrorHandlerOptions) *AccessList {
+	a := &AccessList{
+		peerBans:     make(map[peer.ID]*Ban),
+		subnetBans:   make(map[string]*Ban),
+		allowedPeers: make(map[peer.ID]bool),
+	}
+
+	if opts.BanStateFile != "" {
+		if err := a.Load(opts.BanStateFile); err != nil {
+			log.Warnf("Error loading saved bans: %v", err)
+		}
+	}
+
+	for _, entry := range opts.BanList {
+		ban, err := ParseBan(entry)
+		if err != nil {
+			log.Warnf("Error parsing ban entry: %v", err)
+			continue
+		}
+		a.AddBan(ban)
+	}
+
+	for _, peerStr := range opts.AllowList {
+		id, err := peer.Decode(peerStr)
+		if err != nil {
+			log.Warnf("Error parsing allowed peer %s: %v", peerStr, err)
+			continue
+		}
+		a.AllowPeer(id)
+	}
+
+	return a
+}
+
+// ParseBan converts a ban entry from the options into a Ban.
+// The target may be a peer ID, an IP address, or a CIDR range.
+func ParseBan(entry options.BanEntry) (*Ban, error) {
+	ban := &Ban{
+		Expiration: entry.Expiration,
+		Reason:     entry.Reason,
+	}
+
+	if _, subnet, err := net.ParseCIDR(entry.Target); err == nil {
+		ban.Subnet = subnet
+	} else if ip := net.ParseIP(entry.Target); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		ban.Subnet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	} else if id, err := peer.Decode(entry.Target); err == nil {
+		ban.Peer = id
+	} else {
+		return nil, fmt.Errorf("ban target '%s' is not a peer ID, IP address, or CIDR range", entry.Target)
+	}
+
+	return ban, nil
+}
+
+// AddBan adds or replaces a ban
+func (a *AccessList) AddBan(ban *Ban) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if ban.Subnet != nil {
+		a.subnetBans[ban.Subnet.String()] = ban
+	} else {
+		a.peerBans[ban.Peer] = ban
+	}
+}
+
+// RemoveBan removes the ban for the given target. Returns false if there was no such ban.
+func (a *AccessList) RemoveBan(target string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	ban, err := ParseBan(options.BanEntry{Target: target})
+	if err != nil {
+		return false
+	}
+
+	if ban.Subnet != nil {
+		if _, ok := a.subnetBans[ban.Subnet.String()]; ok {
+			delete(a.subnetBans, ban.Subnet.String())
+			return true
+		}
+	} else if _, ok := a.peerBans[ban.Peer]; ok {
+		delete(a.peerBans, ban.Peer)
+		return true
+	}
+
+	return false
+}
+
+// Bans returns all unexpired bans
+func (a *AccessList) Bans() []Ban {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.removeExpired(time.Now())
+
+	bans := make([]Ban, 0, len(a.peerBans)+len(a.subnetBans))
+	for _, ban := range a.peerBans {
+		bans = append(bans, *ban)
+	}
+	for _, ban := range a.subnetBans {
+		bans = append(bans, *ban)
+	}
+
+	return bans
+}
+
+// savedBan is the serialized form of a Ban
+type savedBan struct {
+	Target     string    `json:"target"`
+	Expiration time.Time `json:"expiration,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Offenses   uint64    `json:"offenses,omitempty"`
+}
+
+// Save writes all unexpired bans to the given file
+func (a *AccessList) Save(path string) error {
+	bans := a.Bans()
+	saved := make([]savedBan, 0, len(bans))
+	for _, ban := range bans {
+		saved = append(saved, savedBan{
+			Target:     ban.Target(),
+			Expiration: ban.Expiration,
+			Reason:     ban.Reason,
+			Offenses:   ban.Offenses,
+		})
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so an interrupted save does not lose the previous bans
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// Load adds the bans saved in the given file. A missing file is not an error.
+func (a *AccessList) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	saved := make([]savedBan, 0)
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	now := time.Now()
+	for _, entry := range saved {
+		ban, err := ParseBan(options.BanEntry{Target: entry.Target, Expiration: entry.Expiration, Reason: entry.Reason})
+		if err != nil {
+			log.Warnf("Error parsing saved ban: %v", err)
+			continue
+		}
+
+		ban.Offenses = entry.Offenses
+		if !ban.IsExpired(now) {
+			a.AddBan(ban)
+		}
+	}
+
+	return nil
+}
+
+// AllowPeer adds a peer to the allow list
+func (a *AccessList) AllowPeer(id peer.ID) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.allowedPeers[id] = true
+}
+
+// DisallowPeer removes a peer from the allow list
+func (a *AccessList) DisallowPeer(id peer.ID) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.allowedPeers, id)
+}
+
+// IsAllowed returns if the peer is on the allow list
+func (a *AccessList) IsAllowed(id peer.ID) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.allowedPeers[id]
+}
+
+// AllowedPeers returns the peers on the allow list
+func (a *AccessList) AllowedPeers() []peer.ID {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	peers := make([]peer.ID, 0, len(a.allowedPeers))
+	for id := range a.allowedPeers {
+		peers = append(peers, id)
+	}
+
+	return peers
+}
+
+// GetPeerBan returns the unexpired ban of the peer, if any
+func (a *AccessList) GetPeerBan(id peer.ID) (Ban, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if ban, ok := a.peerBans[id]; ok && !ban.IsExpired(time.Now()) {
+		return *ban, true
+	}
+
+	return Ban{}, false
+}
+
+// IsPeerBanned returns if the peer is banned
+func (a *AccessList) IsPeerBanned(id peer.ID) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if ban, ok := a.peerBans[id]; ok {
+		return !ban.IsExpired(time.Now())
+	}
+
+	return false
+}
+
+// IsAddressBanned returns if the IP address of the multiaddress falls within a banned range
+func (a *AccessList) IsAddressBanned(addr multiaddr.Multiaddr) bool {
+	if addr == nil {
+		return false
+	}
+
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return false
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	now := time.Now()
+	for _, ban := range a.subnetBans {
+		if ban.Subnet.Contains(ip) && !ban.IsExpired(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *AccessList) removeExpired(now time.Time) {
+	for id, ban := range a.peerBans {
+		if ban.IsExpired(now) {
+			delete(a.peerBans, id)
+		}
+	}
+
+	for subnet, ban := range a.subnetBans {
+		if ban.IsExpired(now) {
+			delete(a.subnetBans, subnet)
+		}
+	}
+}
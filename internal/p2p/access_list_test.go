@@ -0,0 +1,139 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+type testConnMultiaddrs struct {
+	remote multiaddr.Multiaddr
+}
+
+func (t *testConnMultiaddrs) LocalMultiaddr() multiaddr.Multiaddr {
+	return multiaddr.StringCast("/ip4/127.0.0.1/tcp/8888")
+}
+
+func (t *testConnMultiaddrs) RemoteMultiaddr() multiaddr.Multiaddr {
+	return t.remote
+}
+
+func TestAccessList(t *testing.T) {
+	const peerStr = "QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+
+	ban, err := options.ParseBanEntry(peerStr + "|1h|spamming")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if ban.Reason != "spamming" || time.Until(ban.Expiration) < time.Minute*59 {
+		t.Errorf("Ban entry was not parsed correctly")
+	}
+
+	if _, err = options.ParseBanEntry("|1h"); err == nil {
+		t.Errorf("Ban entry without a target should give an error, but it did not")
+	}
+
+	opts := options.NewPeerErrorHandlerOptions()
+	opts.BanList = []options.BanEntry{ban, {Target: "10.0.0.0/8"}, {Target: "192.168.1.1"}}
+	opts.AllowList = []string{"QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"}
+
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), make(chan PeerError), make(chan PeerReward), nil, *opts)
+	errorHandler.Start(context.Background())
+
+	bannedPeer, _ := peer.Decode(peerStr)
+	allowedPeer, _ := peer.Decode(opts.AllowList[0])
+	bannedAddr := multiaddr.StringCast("/ip4/10.1.2.3/tcp/8888")
+	bannedHost := multiaddr.StringCast("/ip4/192.168.1.1/tcp/8888")
+	otherAddr := multiaddr.StringCast("/ip4/192.168.1.2/tcp/8888")
+
+	if errorHandler.InterceptPeerDial(bannedPeer) {
+		t.Errorf("Expected failed dial to banned peer")
+	}
+
+	if !errorHandler.InterceptPeerDial(allowedPeer) {
+		t.Errorf("Expected successful dial to allowed peer")
+	}
+
+	if errorHandler.InterceptAccept(&testConnMultiaddrs{bannedAddr}) || errorHandler.InterceptAccept(&testConnMultiaddrs{bannedHost}) {
+		t.Errorf("Expected failed accept from banned address")
+	}
+
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{otherAddr}) {
+		t.Errorf("Expected successful accept from unbanned address")
+	}
+
+	if errorHandler.InterceptAddrDial("peerA", bannedAddr) {
+		t.Errorf("Expected failed dial to banned address")
+	}
+
+	if !errorHandler.InterceptAddrDial(allowedPeer, bannedAddr) {
+		t.Errorf("Expected successful dial to allowed peer on banned address")
+	}
+
+	if errorHandler.InterceptSecured(network.DirInbound, "peerA", &testConnMultiaddrs{bannedAddr}) {
+		t.Errorf("Expected failed secured connection from banned address")
+	}
+
+	// Bans can be changed at runtime and expire
+	if !errorHandler.AccessList.RemoveBan("10.0.0.0/8") {
+		t.Errorf("Expected ban to be removed")
+	}
+
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{bannedAddr}) {
+		t.Errorf("Expected successful accept after ban was removed")
+	}
+
+	errorHandler.AccessList.AddBan(&Ban{Peer: "peerA", Expiration: time.Now().Add(time.Millisecond * 50)})
+	if errorHandler.InterceptPeerDial("peerA") {
+		t.Errorf("Expected failed dial to banned peer")
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	if !errorHandler.InterceptPeerDial("peerA") {
+		t.Errorf("Expected successful dial after ban expired")
+	}
+
+	if len(errorHandler.AccessList.Bans()) != 2 {
+		t.Errorf("Incorrect number of bans. Expected 2, was %v", len(errorHandler.AccessList.Bans()))
+	}
+}
+
+func TestAllowOnly(t *testing.T) {
+	opts := options.NewPeerErrorHandlerOptions()
+	opts.AllowList = []string{"QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"}
+	opts.AllowOnly = true
+
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), make(chan PeerError), make(chan PeerReward), nil, *opts)
+	errorHandler.Start(context.Background())
+
+	allowedPeer, _ := peer.Decode(opts.AllowList[0])
+	addr := multiaddr.StringCast("/ip4/192.168.1.2/tcp/8888")
+
+	if !errorHandler.InterceptPeerDial(allowedPeer) || !errorHandler.InterceptAddrDial(allowedPeer, addr) {
+		t.Errorf("Expected successful dial to allowed peer")
+	}
+
+	if errorHandler.InterceptPeerDial("peerA") || errorHandler.InterceptAddrDial("peerA", addr) {
+		t.Errorf("Expected failed dial to peer that is not allowed")
+	}
+
+	// The peer is only known once the connection is secured
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{addr}) {
+		t.Errorf("Expected successful accept before the peer is known")
+	}
+
+	if errorHandler.InterceptSecured(network.DirInbound, "peerA", &testConnMultiaddrs{addr}) {
+		t.Errorf("Expected failed secured connection from peer that is not allowed")
+	}
+
+	if !errorHandler.InterceptSecured(network.DirInbound, allowedPeer, &testConnMultiaddrs{addr}) {
+		t.Errorf("Expected successful secured connection from allowed peer")
+	}
+}
@@ -0,0 +1,10 @@
+package p2p
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// BanProvider is an interface for providing the ban state of peers
+type BanProvider interface {
+	GetPeerBan(id peer.ID) (Ban, bool)
+}
@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+)
+
+// blockRequestBatch adapts the number of blocks requested from a peer at once.
+// The size grows additively after every full batch and shrinks multiplicatively after every timeout.
+type blockRequestBatch struct {
+	size       uint64
+	throughput float64 // Blocks per second, zero until a batch has been received
+	opts       *options.PeerConnectionOptions
+}
+
+func newBlockRequestBatch(opts *options.PeerConnectionOptions) *blockRequestBatch {
+	b := &blockRequestBatch{size: opts.BlockRequestBatchSize, opts: opts}
+	b.clamp()
+	return b
+}
+
+func (b *blockRequestBatch) clamp() {
+	if b.size < b.opts.BlockRequestMinBatchSize {
+		b.size = b.opts.BlockRequestMinBatchSize
+	}
+	if b.opts.BlockRequestMaxBatchSize > 0 && b.size > b.opts.BlockRequestMaxBatchSize {
+		b.size = b.opts.BlockRequestMaxBatchSize
+	}
+	// Peers reject requests for more blocks than this
+	if b.size > rpc.MaxBlocksLimit {
+		b.size = rpc.MaxBlocksLimit
+	}
+	if b.size == 0 {
+		b.size = 1
+	}
+}
+
+// timeout returns the timeout of a request for count blocks, based on the peer's observed throughput
+func (b *blockRequestBatch) timeout(count uint64) time.Duration {
+	timeout := b.opts.BlockRequestTimeout
+	if b.throughput > 0 {
+		expected := time.Duration(b.opts.BlockRequestTimeoutMargin * float64(count) / b.throughput * float64(time.Second))
+		if expected > timeout {
+			timeout = expected
+		}
+	}
+
+	if b.opts.BlockRequestMaxTimeout > b.opts.BlockRequestTimeout && timeout > b.opts.BlockRequestMaxTimeout {
+		timeout = b.opts.BlockRequestMaxTimeout
+	}
+
+	return timeout
+}
+
+// succeeded records a received batch of count blocks and grows the batch size if the batch was full
+func (b *blockRequestBatch) succeeded(count uint64, elapsed time.Duration) {
+	if count > 0 && elapsed > 0 {
+		throughput := float64(count) / elapsed.Seconds()
+		if b.throughput == 0 {
+			b.throughput = throughput
+		} else {
+			b.throughput = b.opts.BlockThroughputSmoothingFactor*throughput + (1-b.opts.BlockThroughputSmoothingFactor)*b.throughput
+		}
+	}
+
+	if count >= b.size {
+		b.size += b.opts.BlockRequestBatchIncrease
+		b.clamp()
+	}
+}
+
+// timedOut shrinks the batch size after a request timed out
+func (b *blockRequestBatch) timedOut() {
+	b.size = uint64(float64(b.size) * b.opts.BlockRequestBatchDecreaseFactor)
+	b.clamp()
+}
@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+)
+
+func TestBlockRequestBatchSize(t *testing.T) {
+	opts := options.NewPeerConnectionOptions()
+	opts.BlockRequestBatchSize = 1000
+	opts.BlockRequestMinBatchSize = 100
+	opts.BlockRequestMaxBatchSize = 1200
+	opts.BlockRequestBatchIncrease = 100
+	opts.BlockRequestBatchDecreaseFactor = 0.5
+
+	b := newBlockRequestBatch(opts)
+	if b.size != 1000 {
+		t.Fatalf("Incorrect initial batch size. Expected 1000, was %v", b.size)
+	}
+
+	// A partial batch does not grow the batch size
+	b.succeeded(500, time.Second)
+	if b.size != 1000 {
+		t.Errorf("Expected batch size to be unchanged after a partial batch, was %v", b.size)
+	}
+
+	b.succeeded(1000, time.Second)
+	if b.size != 1100 {
+		t.Errorf("Incorrect batch size after a full batch. Expected 1100, was %v", b.size)
+	}
+
+	b.succeeded(1100, time.Second)
+	b.succeeded(1200, time.Second)
+	if b.size != 1200 {
+		t.Errorf("Expected batch size to be bounded by the max batch size, was %v", b.size)
+	}
+
+	b.timedOut()
+	if b.size != 600 {
+		t.Errorf("Incorrect batch size after a timeout. Expected 600, was %v", b.size)
+	}
+
+	for i := 0; i < 10; i++ {
+		b.timedOut()
+	}
+	if b.size != 100 {
+		t.Errorf("Expected batch size to be bounded by the min batch size, was %v", b.size)
+	}
+}
+
+func TestBlockRequestBatchTimeout(t *testing.T) {
+	opts := options.NewPeerConnectionOptions()
+	opts.BlockRequestTimeout = time.Second * 5
+	opts.BlockRequestTimeoutMargin = 2
+	opts.BlockRequestMaxTimeout = time.Second * 30
+	opts.BlockThroughputSmoothingFactor = 0.5
+
+	b := newBlockRequestBatch(opts)
+	if b.timeout(1000) != time.Second*5 {
+		t.Errorf("Expected min timeout before the throughput is known, was %v", b.timeout(1000))
+	}
+
+	// 100 blocks per second
+	b.succeeded(100, time.Second)
+	if b.timeout(1000) != time.Second*20 {
+		t.Errorf("Incorrect timeout. Expected %v, was %v", time.Second*20, b.timeout(1000))
+	}
+
+	if b.timeout(100) != time.Second*5 {
+		t.Errorf("Expected timeout to be no less than the min timeout, was %v", b.timeout(100))
+	}
+
+	// Smoothed to 200 blocks per second
+	b.succeeded(300, time.Second)
+	if b.timeout(1000) != time.Second*10 {
+		t.Errorf("Incorrect smoothed timeout. Expected %v, was %v", time.Second*10, b.timeout(1000))
+	}
+
+	// 1 block per second
+	for i := 0; i < 20; i++ {
+		b.succeeded(1, time.Second)
+	}
+	if b.timeout(1000) != time.Second*30 {
+		t.Errorf("Expected timeout to be bounded by the max timeout, was %v", b.timeout(1000))
+	}
+}
+
+func TestBlockRequestBatchPeerLimit(t *testing.T) {
+	opts := options.NewPeerConnectionOptions()
+	opts.BlockRequestBatchSize = rpc.MaxBlocksLimit * 2
+	opts.BlockRequestMaxBatchSize = 0
+
+	// Peers reject requests over their limit, even if the max batch size allows them
+	b := newBlockRequestBatch(opts)
+	if b.size != rpc.MaxBlocksLimit {
+		t.Errorf("Expected batch size to be bounded by the peer limit, was %v", b.size)
+	}
+}
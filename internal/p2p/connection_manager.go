@@ -3,10 +3,13 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sort"
 	"time"
 
 	log "github.com/koinos/koinos-log-golang"
 	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
 	"github.com/koinos/koinos-p2p/internal/rpc"
 	util "github.com/koinos/koinos-util-golang"
 
@@ -20,6 +23,9 @@ import (
 
 const maxSleepBackoff = 30
 
+// blockSyncSelectionInterval is how often block sync peers are reselected as peer heads change
+const blockSyncSelectionInterval = time.Second * 5
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -33,8 +39,32 @@ type connectionMessage struct {
 }
 
 type peerConnectionContext struct {
-	peer   *PeerConnection
-	cancel context.CancelFunc
+	peer       *PeerConnection // Nil while sync is paused
+	handshaked bool
+	synced     bool
+	headHeight uint64
+	rtt        time.Duration // Zero until the peer has responded to a ping
+	blockSync  bool          // Blocks may be requested from the peer
+	cancel     context.CancelFunc
+	cancelPing context.CancelFunc
+}
+
+type peerHead struct {
+	id     peer.ID
+	height uint64
+}
+
+// PeerStatus is the state of a connected peer
+type PeerStatus struct {
+	ID         peer.ID
+	Handshaked bool
+	Synced     bool
+	HeadHeight uint64
+	RTT        time.Duration
+}
+
+type peerStatusRequest struct {
+	resultChan chan<- []PeerStatus
 }
 
 type libValue struct {
@@ -53,6 +83,10 @@ type connectionStatus struct {
 	returnChan chan<- error
 }
 
+type syncedPeersRequest struct {
+	resultChan chan<- []peer.ID
+}
+
 // ConnectionManager attempts to reconnect to peers using the network.Notifiee interface.
 type ConnectionManager struct {
 	host   host.Host
@@ -62,26 +96,50 @@ type ConnectionManager struct {
 	localRPC    rpc.LocalRPC
 	peerOpts    *options.PeerConnectionOptions
 	libProvider LastIrreversibleBlockProvider
+	orphanPool  *OrphanBlockPool
+	reputations ReputationProvider
+	bans        BanProvider
+	events      *EventPublisher
 
-	initialPeers       map[peer.ID]peer.AddrInfo
-	connectedPeers     map[peer.ID]*peerConnectionContext
+	initialPeers   map[peer.ID]peer.AddrInfo
+	connectedPeers map[peer.ID]*peerConnectionContext
+	syncPaused     bool
+
+	lastBlockSyncSelection time.Time
 
 	peerConnectedChan        chan connectionMessage
 	peerDisconnectedChan     chan connectionMessage
+	peerVoteChan             chan GossipVote
+	peerHeadChan             chan peerHead
+	peerRTTChan              chan peerRTT
+	syncedPeersChan          chan syncedPeersRequest
+	peerStatusChan           chan peerStatusRequest
+	syncPausedChan           chan bool
+	restartSyncChan          chan struct{}
 	peerErrorChan            chan<- PeerError
+	peerRewardChan           chan<- PeerReward
 	gossipVoteChan           chan<- GossipVote
+	syncedBlocksChan         chan<- SyncedBlocks
 	signalPeerDisconnectChan chan<- peer.ID
+	done                     chan struct{}
 }
 
 // NewConnectionManager creates a new PeerReconnectManager object
 func NewConnectionManager(
 	host host.Host,
 	localRPC rpc.LocalRPC,
+	submissions rpc.SubmissionHandler,
 	peerOpts *options.PeerConnectionOptions,
 	libProvider LastIrreversibleBlockProvider,
+	orphanPool *OrphanBlockPool,
+	reputations ReputationProvider,
+	bans BanProvider,
+	events *EventPublisher,
 	initialPeers []string,
 	peerErrorChan chan<- PeerError,
+	peerRewardChan chan<- PeerReward,
 	gossipVoteChan chan<- GossipVote,
+	syncedBlocksChan chan<- SyncedBlocks,
 	signalPeerDisconnectChan chan<- peer.ID) *ConnectionManager {
 
 	connectionManager := ConnectionManager{
@@ -91,17 +149,32 @@ func NewConnectionManager(
 		localRPC:                 localRPC,
 		peerOpts:                 peerOpts,
 		libProvider:              libProvider,
+		orphanPool:               orphanPool,
+		reputations:              reputations,
+		bans:                     bans,
+		events:                   events,
 		initialPeers:             make(map[peer.ID]peer.AddrInfo),
 		connectedPeers:           make(map[peer.ID]*peerConnectionContext),
+		syncPaused:               true,
 		peerConnectedChan:        make(chan connectionMessage),
 		peerDisconnectedChan:     make(chan connectionMessage),
+		peerVoteChan:             make(chan GossipVote),
+		peerHeadChan:             make(chan peerHead),
+		peerRTTChan:              make(chan peerRTT),
+		syncedPeersChan:          make(chan syncedPeersRequest),
+		peerStatusChan:           make(chan peerStatusRequest),
+		syncPausedChan:           make(chan bool),
+		restartSyncChan:          make(chan struct{}),
 		peerErrorChan:            peerErrorChan,
+		peerRewardChan:           peerRewardChan,
 		gossipVoteChan:           gossipVoteChan,
+		syncedBlocksChan:         syncedBlocksChan,
 		signalPeerDisconnectChan: signalPeerDisconnectChan,
+		done:                     make(chan struct{}),
 	}
 
 	log.Debug("Registering Peer RPC Service")
-	err := connectionManager.server.Register(rpc.NewPeerRPCService(connectionManager.localRPC))
+	err := connectionManager.server.Register(rpc.NewPeerRPCService(connectionManager.localRPC, submissions))
 	if err != nil {
 		log.Errorf("Error registering Peer RPC Service: %s", err.Error())
 		panic(err)
@@ -125,6 +198,16 @@ func NewConnectionManager(
 	return &connectionManager
 }
 
+// InitialPeerIDs returns the IDs of the peers the manager keeps connected to
+func (c *ConnectionManager) InitialPeerIDs() []peer.ID {
+	ids := make([]peer.ID, 0, len(c.initialPeers))
+	for id := range c.initialPeers {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
 // OpenedStream is part of the libp2p network.Notifiee interface
 func (c *ConnectionManager) OpenedStream(n network.Network, s network.Stream) {
 }
@@ -135,12 +218,21 @@ func (c *ConnectionManager) ClosedStream(n network.Network, s network.Stream) {
 
 // Connected is part of the libp2p network.Notifiee interface
 func (c *ConnectionManager) Connected(net network.Network, conn network.Conn) {
-	c.peerConnectedChan <- connectionMessage{net: net, conn: conn}
+	select {
+	case c.peerConnectedChan <- connectionMessage{net: net, conn: conn}:
+	case <-c.done:
+	}
 }
 
 // Disconnected is part of the libp2p network.Notifiee interface
+//
+// Closing the host notifies of every remaining connection, so notifications after the
+// manager has stopped are dropped rather than blocking the close.
 func (c *ConnectionManager) Disconnected(net network.Network, conn network.Conn) {
-	c.peerDisconnectedChan <- connectionMessage{net: net, conn: conn}
+	select {
+	case c.peerDisconnectedChan <- connectionMessage{net: net, conn: conn}:
+	case <-c.done:
+	}
 }
 
 // Listen is part of the libp2p network.Notifiee interface
@@ -158,30 +250,149 @@ func (c *ConnectionManager) handleConnected(ctx context.Context, msg connectionM
 	log.Infof("Connected to peer: %s", s)
 
 	if _, ok := c.connectedPeers[pid]; !ok {
-		childCtx, cancel := context.WithCancel(ctx)
-		peerConn := &peerConnectionContext{
-			peer: NewPeerConnection(
-				pid,
-				c.libProvider,
-				c.localRPC,
-				rpc.NewPeerRPC(c.client, pid),
-				c.peerErrorChan,
-				c.gossipVoteChan,
-				c.peerOpts,
-			),
-			cancel: cancel,
+		peerConn := &peerConnectionContext{}
+		if !c.syncPaused {
+			c.startPeerConnection(ctx, pid, peerConn)
 		}
 
-		peerConn.peer.Start(childCtx)
+		// Peers are pinged even while sync is paused
+		var pingCtx context.Context
+		pingCtx, peerConn.cancelPing = context.WithCancel(ctx)
+		go c.pingLoop(pingCtx, pid)
+
 		c.connectedPeers[pid] = peerConn
+		c.events.PeerConnected(pid, msg.conn.RemoteMultiaddr().String())
+	}
+
+	if c.peerOpts.MaxPeers > 0 && uint64(len(c.connectedPeers)) > c.peerOpts.MaxPeers {
+		c.pruneLowestReputationPeer(ctx)
+	}
+
+	c.selectBlockSyncPeers(ctx)
+}
+
+func (c *ConnectionManager) startPeerConnection(ctx context.Context, pid peer.ID, peerConn *peerConnectionContext) {
+	childCtx, cancel := context.WithCancel(ctx)
+	peerConn.peer = NewPeerConnection(
+		pid,
+		c.libProvider,
+		c.orphanPool,
+		c.localRPC,
+		rpc.NewPeerRPC(c.client, pid),
+		c.peerErrorChan,
+		c.peerRewardChan,
+		c.peerVoteChan,
+		c.peerHeadChan,
+		c.syncedBlocksChan,
+		c.peerOpts,
+	)
+	peerConn.cancel = cancel
+	peerConn.peer.setRTT(peerConn.rtt)
+	peerConn.peer.setBlockSync(peerConn.blockSync)
+
+	peerConn.peer.Start(childCtx)
+}
+
+func (c *ConnectionManager) stopPeerConnection(peerConn *peerConnectionContext) {
+	if peerConn.peer == nil {
+		return
+	}
+
+	peerConn.cancel()
+	peerConn.peer = nil
+	peerConn.handshaked = false
+	peerConn.synced = false
+}
+
+// handleSyncPaused stops syncing with all peers while paused, and restarts the handshake with all peers when resumed
+func (c *ConnectionManager) handleSyncPaused(ctx context.Context, paused bool) {
+	if paused == c.syncPaused {
+		return
+	}
+
+	c.syncPaused = paused
+	for pid, peerConn := range c.connectedPeers {
+		if paused {
+			c.stopPeerConnection(peerConn)
+		} else {
+			c.startPeerConnection(ctx, pid, peerConn)
+		}
+	}
+}
+
+func (c *ConnectionManager) handleRestartSync(ctx context.Context) {
+	if c.syncPaused {
+		return
+	}
+
+	for pid, peerConn := range c.connectedPeers {
+		c.stopPeerConnection(peerConn)
+		c.startPeerConnection(ctx, pid, peerConn)
+	}
+}
+
+// RestartSync repeats the handshake and restarts syncing with all peers
+func (c *ConnectionManager) RestartSync(ctx context.Context) {
+	select {
+	case c.restartSyncChan <- struct{}{}:
+	case <-ctx.Done():
+	}
+}
+
+// PauseSync stops syncing with peers. Peers remain connected.
+func (c *ConnectionManager) PauseSync(ctx context.Context) {
+	select {
+	case c.syncPausedChan <- true:
+	case <-ctx.Done():
+	}
+}
+
+// ResumeSync starts syncing with peers. Sync is paused until ResumeSync is first called.
+func (c *ConnectionManager) ResumeSync(ctx context.Context) {
+	select {
+	case c.syncPausedChan <- false:
+	case <-ctx.Done():
+	}
+}
+
+// pruneLowestReputationPeer disconnects from the connected peer with the lowest reputation.
+// Initial peers are never pruned.
+func (c *ConnectionManager) pruneLowestReputationPeer(ctx context.Context) {
+	candidates := make([]peer.ID, 0, len(c.connectedPeers))
+	for pid := range c.connectedPeers {
+		if _, ok := c.initialPeers[pid]; !ok {
+			candidates = append(candidates, pid)
+		}
 	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	reputations := c.reputations.GetReputations(ctx, candidates)
+	prune := candidates[0]
+	for _, pid := range candidates[1:] {
+		if reputations[pid] < reputations[prune] {
+			prune = pid
+		}
+	}
+
+	log.Infof("Over peer limit, disconnecting from peer %v with reputation %v", prune, reputations[prune])
+
+	// Closing the connection notifies the connection manager, so it cannot be done from the manager loop
+	go func() {
+		if err := c.host.Network().ClosePeer(prune); err != nil {
+			log.Warnf("Error disconnecting from peer %v: %s", prune, err)
+		}
+	}()
 }
 
 func (c *ConnectionManager) handleDisconnected(ctx context.Context, msg connectionMessage) {
 	pid := msg.conn.RemotePeer()
 
 	if peerConn, ok := c.connectedPeers[pid]; ok {
-		peerConn.cancel()
+		c.stopPeerConnection(peerConn)
+		peerConn.cancelPing()
 		delete(c.connectedPeers, pid)
 	} else {
 		return
@@ -189,11 +400,18 @@ func (c *ConnectionManager) handleDisconnected(ctx context.Context, msg connecti
 
 	s := fmt.Sprintf("%s/p2p/%s", msg.conn.RemoteMultiaddr(), msg.conn.RemotePeer())
 	log.Infof("Disconnected from peer: %s", s)
+	c.events.PeerDisconnected(pid, msg.conn.RemoteMultiaddr().String())
+
+	c.selectBlockSyncPeers(ctx)
 
 	if addr, ok := c.initialPeers[pid]; ok {
 		go func() {
 			sleepTimeSeconds := 1
 			for {
+				if !c.waitForBan(ctx, addr.ID) {
+					return
+				}
+
 				log.Infof("Attempting to connect to peer %v", addr.ID)
 				err := c.host.Connect(ctx, addr)
 				if err != nil {
@@ -202,7 +420,11 @@ func (c *ConnectionManager) handleDisconnected(ctx context.Context, msg connecti
 					return
 				}
 
-				time.Sleep(time.Duration(sleepTimeSeconds) * time.Second)
+				select {
+				case <-time.After(time.Duration(sleepTimeSeconds) * time.Second):
+				case <-ctx.Done():
+					return
+				}
 				sleepTimeSeconds = min(maxSleepBackoff, sleepTimeSeconds*2)
 			}
 		}()
@@ -216,6 +438,249 @@ func (c *ConnectionManager) handleDisconnected(ctx context.Context, msg connecti
 	}()
 }
 
+// waitForBan waits until a temporary ban of the peer expires.
+// Returns false if the peer is permanently banned or the context is done.
+func (c *ConnectionManager) waitForBan(ctx context.Context, id peer.ID) bool {
+	ban, banned := c.bans.GetPeerBan(id)
+	if !banned {
+		return true
+	}
+
+	if ban.Expiration.IsZero() {
+		log.Infof("Not connecting to permanently banned peer %v", id)
+		return false
+	}
+
+	log.Infof("Peer %v is banned, waiting until %s to connect", id, ban.Expiration.Format(time.RFC3339))
+	select {
+	case <-time.After(time.Until(ban.Expiration)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// GetRemoteRPC satisfies the RemoteRPCProvider interface
+func (c *ConnectionManager) GetRemoteRPC(id peer.ID) rpc.RemoteRPC {
+	return rpc.NewPeerRPC(c.client, id)
+}
+
+func (c *ConnectionManager) handleVote(ctx context.Context, vote GossipVote) {
+	// Peers only vote once they have completed the handshake
+	if peerConn, ok := c.connectedPeers[vote.peer]; ok && peerConn.peer != nil {
+		if !peerConn.handshaked {
+			c.events.PeerHandshaked(vote.peer)
+		}
+		peerConn.handshaked = true
+		peerConn.synced = vote.synced
+	}
+
+	go func() {
+		select {
+		case c.gossipVoteChan <- vote:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// handleSyncedPeers returns the synced peers ordered by round trip time. Peers that have not responded to a ping are last.
+func (c *ConnectionManager) handleSyncedPeers() []peer.ID {
+	peers := make([]peer.ID, 0, len(c.connectedPeers))
+	for pid, peerConn := range c.connectedPeers {
+		if peerConn.synced {
+			peers = append(peers, pid)
+		}
+	}
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	sort.SliceStable(peers, func(i, j int) bool {
+		rttI, rttJ := c.connectedPeers[peers[i]].rtt, c.connectedPeers[peers[j]].rtt
+		if rttI == 0 || rttJ == 0 {
+			return rttJ == 0 && rttI != 0
+		}
+		return rttI < rttJ
+	})
+
+	return peers
+}
+
+func (c *ConnectionManager) handlePeerHead(ctx context.Context, head peerHead) {
+	if peerConn, ok := c.connectedPeers[head.id]; ok {
+		peerConn.headHeight = head.height
+	}
+
+	if time.Since(c.lastBlockSyncSelection) >= blockSyncSelectionInterval {
+		c.selectBlockSyncPeers(ctx)
+	}
+}
+
+// selectBlockSyncPeers chooses the peers blocks are requested from. Peers ahead of our last irreversible block
+// are preferred, then peers with the highest reputation.
+func (c *ConnectionManager) selectBlockSyncPeers(ctx context.Context) {
+	c.lastBlockSyncSelection = time.Now()
+
+	peers := make([]peer.ID, 0, len(c.connectedPeers))
+	for pid := range c.connectedPeers {
+		peers = append(peers, pid)
+	}
+
+	selected := uint64(len(peers))
+	if c.peerOpts.BlockSyncPeers > 0 && selected > c.peerOpts.BlockSyncPeers {
+		selected = c.peerOpts.BlockSyncPeers
+
+		lib := c.libProvider.GetLastIrreversibleBlock()
+		reputations := c.reputations.GetReputations(ctx, peers)
+		rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+		sort.SliceStable(peers, func(i, j int) bool {
+			aheadI, aheadJ := c.connectedPeers[peers[i]].headHeight > lib.Height, c.connectedPeers[peers[j]].headHeight > lib.Height
+			if aheadI != aheadJ {
+				return aheadI
+			}
+			return reputations[peers[i]] > reputations[peers[j]]
+		})
+	}
+
+	for i, pid := range peers {
+		peerConn := c.connectedPeers[pid]
+		peerConn.blockSync = uint64(i) < selected
+		if peerConn.peer != nil {
+			peerConn.peer.setBlockSync(peerConn.blockSync)
+		}
+	}
+}
+
+func (c *ConnectionManager) handlePeerStatus() []PeerStatus {
+	statuses := make([]PeerStatus, 0, len(c.connectedPeers))
+	for pid, peerConn := range c.connectedPeers {
+		statuses = append(statuses, PeerStatus{
+			ID:         pid,
+			Handshaked: peerConn.handshaked,
+			Synced:     peerConn.synced,
+			HeadHeight: peerConn.headHeight,
+			RTT:        peerConn.rtt,
+		})
+	}
+
+	return statuses
+}
+
+// GetPeerStatus returns the state of all connected peers
+func (c *ConnectionManager) GetPeerStatus(ctx context.Context) []PeerStatus {
+	resultChan := make(chan []PeerStatus, 1)
+	select {
+	case c.peerStatusChan <- peerStatusRequest{resultChan: resultChan}:
+	case <-ctx.Done():
+		return nil
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// GetSyncedPeers returns the connected peers that we are currently synced with, lowest round trip time first
+func (c *ConnectionManager) GetSyncedPeers(ctx context.Context) []peer.ID {
+	resultChan := make(chan []peer.ID, 1)
+	select {
+	case c.syncedPeersChan <- syncedPeersRequest{resultChan: resultChan}:
+	case <-ctx.Done():
+		return nil
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// SyncPendingTransactions requests pending transactions from synced peers and submits them to the local mempool.
+// Peers with the highest reputation are preferred, then peers with the lowest round trip time.
+func (c *ConnectionManager) SyncPendingTransactions(ctx context.Context) {
+	peers := c.GetSyncedPeers(ctx)
+	reputations := c.reputations.GetReputations(ctx, peers)
+	sort.SliceStable(peers, func(i, j int) bool { return reputations[peers[i]] > reputations[peers[j]] })
+	if uint64(len(peers)) > c.peerOpts.PendingTransactionsSyncPeers {
+		peers = peers[:c.peerOpts.PendingTransactionsSyncPeers]
+	}
+
+	if len(peers) == 0 {
+		return
+	}
+
+	log.Infof("Requesting pending transactions from %v peers", len(peers))
+
+	seen := make(map[string]util.Void)
+	applied := 0
+
+	for _, pid := range peers {
+		count, err := c.syncPendingTransactionsFromPeer(ctx, pid, seen)
+		applied += count
+		if err != nil {
+			log.Infof("Error requesting pending transactions from peer %v: %s", pid, err)
+			go func(pid peer.ID, err error) {
+				select {
+				case c.peerErrorChan <- PeerError{id: pid, err: err}:
+				case <-ctx.Done():
+				}
+			}(pid, err)
+		}
+	}
+
+	log.Infof("Applied %v pending transactions from peers", applied)
+}
+
+func (c *ConnectionManager) syncPendingTransactionsFromPeer(ctx context.Context, pid peer.ID, seen map[string]util.Void) (int, error) {
+	peerRPC := c.GetRemoteRPC(pid)
+	applied := 0
+	start := uint64(0)
+
+	for start < c.peerOpts.PendingTransactionsMaxCount {
+		rpcContext, cancelGetPending := context.WithTimeout(ctx, c.peerOpts.RemoteRPCTimeout)
+		transactions, more, err := peerRPC.GetPendingTransactions(rpcContext, start, c.peerOpts.PendingTransactionsPageSize, c.peerOpts.PendingTransactionsMaxBytes)
+		cancelGetPending()
+		if err != nil {
+			return applied, err
+		}
+
+		for _, trx := range transactions {
+			if trx.Id == nil {
+				return applied, fmt.Errorf("%w, pending transaction missing id", p2perrors.ErrDeserialization)
+			}
+
+			if _, ok := seen[string(trx.Id)]; ok {
+				continue
+			}
+			seen[string(trx.Id)] = util.Void{}
+
+			rpcContext, cancelApplyTransaction := context.WithTimeout(ctx, c.peerOpts.ApplyTransactionTimeout)
+			_, err = c.localRPC.ApplyTransaction(rpcContext, trx)
+			cancelApplyTransaction()
+
+			// The transaction may have been included in a block or expired since the peer sent it,
+			// so application failures are expected and not attributed to the peer
+			if err != nil {
+				log.Debugf("Pending transaction not applied - %s, %s", util.TransactionString(trx), err)
+				continue
+			}
+
+			applied++
+		}
+
+		if !more || len(transactions) == 0 {
+			break
+		}
+
+		start += uint64(len(transactions))
+	}
+
+	return applied, nil
+}
+
 func (c *ConnectionManager) connectInitialPeers(ctx context.Context) {
 	newlyConnectedPeers := make(map[peer.ID]util.Void)
 	peersToConnect := make(map[peer.ID]peer.AddrInfo)
@@ -226,7 +691,15 @@ func (c *ConnectionManager) connectInitialPeers(ctx context.Context) {
 	}
 
 	for len(peersToConnect) > 0 {
-		for peer, addr := range c.initialPeers {
+		for peer, addr := range peersToConnect {
+			// Banned peers are retried once the ban expires, permanently banned peers never are
+			if ban, banned := c.bans.GetPeerBan(peer); banned {
+				if ban.Expiration.IsZero() {
+					delete(peersToConnect, peer)
+				}
+				continue
+			}
+
 			log.Infof("Attempting to connect to peer %v", peer)
 			err := c.host.Connect(ctx, addr)
 			if err != nil {
@@ -242,7 +715,11 @@ func (c *ConnectionManager) connectInitialPeers(ctx context.Context) {
 
 		newlyConnectedPeers = make(map[peer.ID]util.Void)
 
-		time.Sleep(time.Duration(sleepTimeSeconds) * time.Second)
+		select {
+		case <-time.After(time.Duration(sleepTimeSeconds) * time.Second):
+		case <-ctx.Done():
+			return
+		}
 		sleepTimeSeconds = min(maxSleepBackoff, sleepTimeSeconds*2)
 	}
 }
@@ -254,13 +731,29 @@ func (c *ConnectionManager) managerLoop(ctx context.Context) {
 			c.handleConnected(ctx, connMsg)
 		case connMsg := <-c.peerDisconnectedChan:
 			c.handleDisconnected(ctx, connMsg)
+		case vote := <-c.peerVoteChan:
+			c.handleVote(ctx, vote)
+		case head := <-c.peerHeadChan:
+			c.handlePeerHead(ctx, head)
+		case sample := <-c.peerRTTChan:
+			c.handlePeerRTT(sample)
+		case req := <-c.syncedPeersChan:
+			req.resultChan <- c.handleSyncedPeers()
+		case req := <-c.peerStatusChan:
+			req.resultChan <- c.handlePeerStatus()
+		case paused := <-c.syncPausedChan:
+			c.handleSyncPaused(ctx, paused)
+		case <-c.restartSyncChan:
+			c.handleRestartSync(ctx)
 
 		case <-ctx.Done():
 			for _, conn := range c.connectedPeers {
-				conn.cancel()
+				c.stopPeerConnection(conn)
+				conn.cancelPing()
 			}
 
 			c.connectedPeers = make(map[peer.ID]*peerConnectionContext)
+			close(c.done)
 			return
 		}
 	}
@@ -268,15 +761,16 @@ func (c *ConnectionManager) managerLoop(ctx context.Context) {
 
 // Start the connection manager
 func (c *ConnectionManager) Start(ctx context.Context) {
+	go c.managerLoop(ctx)
+
 	go func() {
 		for _, peer := range c.host.Network().Peers() {
 			conns := c.host.Network().ConnsToPeer(peer)
 			if len(conns) > 0 {
-				c.peerConnectedChan <- connectionMessage{net: c.host.Network(), conn: conns[0]}
+				c.Connected(c.host.Network(), conns[0])
 			}
 		}
 
-		go c.connectInitialPeers(ctx)
-		go c.managerLoop(ctx)
+		c.connectInitialPeers(ctx)
 	}()
 }
@@ -0,0 +1,68 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-proto-golang/koinos"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+type testReputationProvider struct {
+	reputations map[peer.ID]int64
+}
+
+func (t *testReputationProvider) GetReputations(ctx context.Context, ids []peer.ID) map[peer.ID]int64 {
+	return t.reputations
+}
+
+func TestSelectBlockSyncPeers(t *testing.T) {
+	opts := options.NewPeerConnectionOptions()
+	opts.BlockSyncPeers = 2
+
+	c := &ConnectionManager{
+		peerOpts:    opts,
+		libProvider: &testGossipLIBProvider{lib: &koinos.BlockTopology{Height: 10}},
+		reputations: &testReputationProvider{reputations: map[peer.ID]int64{
+			"a": 100,
+			"b": 50,
+			"c": -10,
+			"d": 1000,
+		}},
+		connectedPeers: map[peer.ID]*peerConnectionContext{
+			"a": {headHeight: 20},
+			"b": {headHeight: 20},
+			"c": {headHeight: 20},
+			"d": {headHeight: 5},
+		},
+	}
+
+	expectSelected := func(selected ...peer.ID) {
+		t.Helper()
+		for pid, peerConn := range c.connectedPeers {
+			expected := false
+			for _, id := range selected {
+				expected = expected || id == pid
+			}
+
+			if peerConn.blockSync != expected {
+				t.Errorf("Peer %v block sync was %v, expected %v", pid, peerConn.blockSync, expected)
+			}
+		}
+	}
+
+	// The highest reputation peers ahead of us are selected, before a higher reputation peer that is behind
+	c.selectBlockSyncPeers(context.Background())
+	expectSelected("a", "b")
+
+	// Peers that fall behind are replaced
+	c.connectedPeers["b"].headHeight = 10
+	c.selectBlockSyncPeers(context.Background())
+	expectSelected("a", "c")
+
+	// Without a limit, all peers are selected
+	opts.BlockSyncPeers = 0
+	c.selectBlockSyncPeers(context.Background())
+	expectSelected("a", "b", "c", "d")
+}
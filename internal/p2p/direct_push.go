@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// PushBlock submits a block directly to all synced peers, bypassing gossip.
+// Returns the number of peers that accepted the block, or an error if none did.
+func (c *ConnectionManager) PushBlock(ctx context.Context, block *protocol.Block) (int, error) {
+	return c.pushToPeers(ctx, c.GetSyncedPeers(ctx), func(ctx context.Context, peerRPC rpc.RemoteRPC) error {
+		return peerRPC.SubmitBlock(ctx, block)
+	})
+}
+
+// PushBlockToPeers submits a block directly to those of the given peers that are connected, in parallel with gossip.
+// Peers are not dialed, since dialing would defeat the purpose of a low latency push.
+// Returns the number of peers that accepted the block, or an error if none did.
+func (c *ConnectionManager) PushBlockToPeers(ctx context.Context, block *protocol.Block, peers []peer.ID) (int, error) {
+	connected := make([]peer.ID, 0, len(peers))
+	for _, pid := range peers {
+		if c.host.Network().Connectedness(pid) == network.Connected {
+			connected = append(connected, pid)
+		}
+	}
+
+	return c.pushToPeers(ctx, connected, func(ctx context.Context, peerRPC rpc.RemoteRPC) error {
+		return peerRPC.SubmitBlock(ctx, block)
+	})
+}
+
+// PushTransaction submits a transaction directly to all synced peers, bypassing gossip.
+// Returns the number of peers that accepted the transaction, or an error if none did.
+func (c *ConnectionManager) PushTransaction(ctx context.Context, transaction *protocol.Transaction) (int, error) {
+	return c.pushToPeers(ctx, c.GetSyncedPeers(ctx), func(ctx context.Context, peerRPC rpc.RemoteRPC) error {
+		return peerRPC.SubmitTransaction(ctx, transaction)
+	})
+}
+
+// pushToPeers submits to the peers in parallel, returning the number of peers that accepted the submission.
+// Rejections are not peer errors, since a peer may already have the block or transaction.
+func (c *ConnectionManager) pushToPeers(ctx context.Context, peers []peer.ID, submit func(context.Context, rpc.RemoteRPC) error) (int, error) {
+	if len(peers) == 0 {
+		return 0, p2perrors.ErrNoPeers
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := 0
+	var firstErr error
+
+	for _, pid := range peers {
+		wg.Add(1)
+		go func(pid peer.ID) {
+			defer wg.Done()
+
+			rpcContext, cancel := context.WithTimeout(ctx, c.peerOpts.RemoteRPCTimeout)
+			defer cancel()
+			err := submit(rpcContext, c.GetRemoteRPC(pid))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				log.Debugf("Peer %v did not accept direct push: %s", pid, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			accepted++
+		}(pid)
+	}
+
+	wg.Wait()
+
+	if accepted == 0 {
+		return 0, firstErr
+	}
+
+	return accepted, nil
+}
@@ -3,7 +3,9 @@ package p2p
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
+	"net"
 	"time"
 
 	log "github.com/koinos/koinos-log-golang"
@@ -13,6 +15,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	multiaddr "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 )
 
 // PeerError represents an error originating from a peer
@@ -21,33 +24,121 @@ type PeerError struct {
 	err error
 }
 
+// NewPeerError creates a PeerError
+func NewPeerError(id peer.ID, err error) PeerError {
+	return PeerError{id: id, err: err}
+}
+
+// Reward is a kind of useful behavior by a peer
+type Reward int
+
+// Rewards that increase a peer's reputation
+const (
+	// SyncBlockReward is given for each block received during sync that applied
+	SyncBlockReward Reward = iota
+	// GossipBlockReward is given for the first delivery of a gossiped block that applied
+	GossipBlockReward
+	// LowLatencyReward is given for responding to an RPC within the low latency threshold
+	LowLatencyReward
+)
+
+// PeerReward represents useful behavior by a peer
+type PeerReward struct {
+	id     peer.ID
+	reward Reward
+	count  uint64
+}
+
 type errorScoreRecord struct {
 	lastUpdate time.Time
 	score      uint64
 }
 
+type offenseRecord struct {
+	count       uint64
+	lastOffense time.Time
+}
+
 type canConnectRequest struct {
 	id         peer.ID
+	addr       multiaddr.Multiaddr
+	inbound    bool
 	resultChan chan<- bool
 }
 
+type reputationRequest struct {
+	ids        []peer.ID
+	resultChan chan<- map[peer.ID]int64
+}
+
+type errorHistoryRequest struct {
+	id         peer.ID
+	resultChan chan<- map[peer.ID][]PeerErrorRecord
+}
+
+type connectionEvent struct {
+	id        peer.ID
+	addr      multiaddr.Multiaddr
+	connected bool
+}
+
+// ipErrorScorePruneInterval is how often IP addresses with fully decayed error scores are forgotten
+const ipErrorScorePruneInterval = time.Minute
+
 // PeerErrorHandler handles PeerErrors and tracks errors over time
 // to determine if a peer should be disconnected from
 type PeerErrorHandler struct {
-	errorScores        map[peer.ID]*errorScoreRecord
+	errorScores        map[peer.ID]map[options.ErrorCategory]*errorScoreRecord
+	reputations        map[peer.ID]*errorScoreRecord
+	errorHistories     map[peer.ID]*errorHistory
+	offenses           map[peer.ID]*offenseRecord
+	ipErrorScores      map[string]*errorScoreRecord
+	lastIPPrune        time.Time
+	ipConnections      map[string]int
+	subnetConnections  map[string]int
+	peerIPs            map[peer.ID]map[string]int
 	disconnectPeerChan chan<- peer.ID
 	peerErrorChan      <-chan PeerError
+	peerRewardChan     <-chan PeerReward
 	canConnectChan     chan canConnectRequest
+	reputationChan     chan reputationRequest
+	errorHistoryChan   chan errorHistoryRequest
+	connectionChan     chan connectionEvent
+	done               chan struct{}
+
+	// AccessList holds manual bans and peers which are never gated
+	AccessList *AccessList
 
-	opts options.PeerErrorHandlerOptions
+	events *EventPublisher
+	opts   options.PeerErrorHandlerOptions
 }
 
 // CanConnect to peer if the peer's error score is below the error score threshold
 func (p *PeerErrorHandler) CanConnect(ctx context.Context, id peer.ID) bool {
+	return p.canConnect(ctx, canConnectRequest{id: id})
+}
+
+// CanConnectAddr to peer if both the peer's error score and the error score of its IP address are below their thresholds
+func (p *PeerErrorHandler) CanConnectAddr(ctx context.Context, id peer.ID, addr multiaddr.Multiaddr) bool {
+	return p.canConnect(ctx, canConnectRequest{id: id, addr: addr})
+}
+
+// CanAccept an inbound connection from the address if its error score and connection counts are below their limits
+func (p *PeerErrorHandler) CanAccept(ctx context.Context, addr multiaddr.Multiaddr) bool {
+	return p.canConnect(ctx, canConnectRequest{addr: addr, inbound: true})
+}
+
+func (p *PeerErrorHandler) canConnect(ctx context.Context, req canConnectRequest) bool {
 	resultChan := make(chan bool, 1)
-	p.canConnectChan <- canConnectRequest{
-		id:         id,
-		resultChan: resultChan,
+	req.resultChan = resultChan
+
+	// Connections are gated after the handler stops while the host is closing
+	select {
+	case p.canConnectChan <- req:
+	case <-ctx.Done():
+		return false
+	case <-p.done:
+		return false
 	}
 
 	select {
@@ -58,104 +149,560 @@ func (p *PeerErrorHandler) CanConnect(ctx context.Context, id peer.ID) bool {
 	}
 }
 
-func (p *PeerErrorHandler) handleCanConnect(id peer.ID) bool {
-	if record, ok := p.errorScores[id]; ok {
+func (p *PeerErrorHandler) handleCanConnect(req canConnectRequest) bool {
+	if req.id != "" && p.AccessList.IsPeerBanned(req.id) && !p.AccessList.IsAllowed(req.id) {
+		return false
+	}
+
+	p.decayPeerErrorScores(req.id)
+	if p.exceedsErrorThreshold(req.id) {
+		return false
+	}
+
+	if req.addr == nil {
+		return true
+	}
+
+	ip, err := manet.ToIP(req.addr)
+	if err != nil {
+		return true
+	}
+
+	if record, ok := p.ipErrorScores[ip.String()]; ok {
 		p.decayErrorScore(record)
-		return record.score < p.opts.ErrorScoreThreshold
+		if record.score >= p.opts.IPErrorScoreThreshold {
+			return false
+		}
+	}
+
+	if req.inbound {
+		if p.opts.MaxConnectionsPerIP > 0 && uint64(p.ipConnections[ip.String()]) >= p.opts.MaxConnectionsPerIP {
+			log.Debugf("Rejecting connection from %s, too many connections from IP address", req.addr)
+			return false
+		}
+
+		if p.opts.MaxConnectionsPerSubnet > 0 && uint64(p.subnetConnections[p.subnet(ip)]) >= p.opts.MaxConnectionsPerSubnet {
+			log.Debugf("Rejecting connection from %s, too many connections from subnet", req.addr)
+			return false
+		}
 	}
 
 	return true
 }
 
-func (p *PeerErrorHandler) handleError(ctx context.Context, peerErr PeerError) {
-	if record, ok := p.errorScores[peerErr.id]; ok {
+// subnet returns the /24 (IPv4) or /48 (IPv6) subnet containing the IP address, depending on options
+func (p *PeerErrorHandler) subnet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(int(p.opts.IPv4SubnetMaskBits), 8*net.IPv4len)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(int(p.opts.IPv6SubnetMaskBits), 8*net.IPv6len)).String()
+}
+
+func (p *PeerErrorHandler) handleConnectionEvent(event connectionEvent) {
+	if event.addr == nil {
+		return
+	}
+
+	ip, err := manet.ToIP(event.addr)
+	if err != nil {
+		return
+	}
+
+	key := ip.String()
+	subnet := p.subnet(ip)
+
+	if event.connected {
+		p.ipConnections[key]++
+		p.subnetConnections[subnet]++
+
+		if _, ok := p.peerIPs[event.id]; !ok {
+			p.peerIPs[event.id] = make(map[string]int)
+		}
+		p.peerIPs[event.id][key]++
+		return
+	}
+
+	// Ignore disconnects for connections that were never counted
+	ips := p.peerIPs[event.id]
+	if ips[key] <= 0 {
+		return
+	}
+
+	ips[key]--
+	if ips[key] == 0 {
+		delete(ips, key)
+	}
+	if len(ips) == 0 {
+		delete(p.peerIPs, event.id)
+	}
+
+	p.ipConnections[key]--
+	if p.ipConnections[key] <= 0 {
+		delete(p.ipConnections, key)
+	}
+
+	p.subnetConnections[subnet]--
+	if p.subnetConnections[subnet] <= 0 {
+		delete(p.subnetConnections, subnet)
+	}
+}
+
+func (p *PeerErrorHandler) addErrorScore(scores map[string]*errorScoreRecord, key string, score uint64) uint64 {
+	if record, ok := scores[key]; ok {
 		p.decayErrorScore(record)
-		record.score += p.getScoreForError(peerErr.err)
+		record.score += score
 	} else {
-		p.errorScores[peerErr.id] = &errorScoreRecord{
+		scores[key] = &errorScoreRecord{
 			lastUpdate: time.Now(),
-			score:      p.getScoreForError(peerErr.err),
+			score:      score,
 		}
 	}
 
-	log.Infof("Encountered peer error: %s, %s. Current error score: %v", peerErr.id, peerErr.err.Error(), p.errorScores[peerErr.id].score)
+	return scores[key].score
+}
 
-	if p.errorScores[peerErr.id].score >= p.opts.ErrorScoreThreshold {
-		go func() {
-			select {
-			case p.disconnectPeerChan <- peerErr.id:
-			case <-ctx.Done():
+// pruneIPErrorScores forgets IP addresses whose error scores have decayed to zero
+func (p *PeerErrorHandler) pruneIPErrorScores() {
+	now := time.Now()
+	if now.Sub(p.lastIPPrune) < ipErrorScorePruneInterval {
+		return
+	}
+	p.lastIPPrune = now
+
+	for ip, record := range p.ipErrorScores {
+		p.decayErrorScore(record)
+		if record.score == 0 {
+			delete(p.ipErrorScores, ip)
+		}
+	}
+}
+
+func (p *PeerErrorHandler) handleError(ctx context.Context, peerErr PeerError) {
+	category := p.getCategoryForError(peerErr.err)
+	score := p.opts.ErrorScores[category].Score
+
+	p.decayPeerErrorScores(peerErr.id)
+
+	categories, ok := p.errorScores[peerErr.id]
+	if !ok {
+		categories = make(map[options.ErrorCategory]*errorScoreRecord)
+		p.errorScores[peerErr.id] = categories
+	}
+
+	if record, ok := categories[category]; ok {
+		record.score += score
+	} else {
+		categories[category] = &errorScoreRecord{
+			lastUpdate: time.Now(),
+			score:      score,
+		}
+	}
+
+	p.recordError(peerErr.id, PeerErrorRecord{
+		Time:       time.Now(),
+		Category:   category,
+		Message:    peerErr.err.Error(),
+		ScoreDelta: score,
+	})
+
+	// Errors are also attributed to the peer's IP addresses so that new peer IDs from the same host do not start clean
+	p.pruneIPErrorScores()
+	for ip := range p.peerIPs[peerErr.id] {
+		ipScore := p.addErrorScore(p.ipErrorScores, ip, score)
+		if ipScore >= p.opts.IPErrorScoreThreshold {
+			log.Infof("IP address %s error score exceeds threshold: %v", ip, ipScore)
+		}
+	}
+
+	log.Infof("Encountered peer error: %s, %s. Current error score: %v", peerErr.id, peerErr.err.Error(), p.errorScore(peerErr.id))
+
+	if p.exceedsErrorThreshold(peerErr.id) && !p.AccessList.IsAllowed(peerErr.id) && !p.AccessList.IsPeerBanned(peerErr.id) {
+		p.banForErrors(ctx, peerErr.id, category)
+	}
+}
+
+// banForErrors bans a peer whose error score exceeded its threshold. The first offense results in a
+// short ban and each repeated offense escalates the ban duration. Errors in permanent ban categories
+// result in a permanent ban.
+func (p *PeerErrorHandler) banForErrors(ctx context.Context, id peer.ID, category options.ErrorCategory) {
+	now := time.Now()
+	offense, ok := p.offenses[id]
+	if !ok || now.Sub(offense.lastOffense) > p.opts.BanOffenseExpiration {
+		offense = &offenseRecord{}
+		p.offenses[id] = offense
+	}
+	offense.count++
+	offense.lastOffense = now
+
+	var expiration time.Time
+	if !p.isPermanentBanCategory(category) {
+		duration := p.opts.BanDuration
+		for i := uint64(1); i < offense.count && duration < p.opts.MaxBanDuration; i++ {
+			duration *= time.Duration(p.opts.BanEscalationFactor)
+		}
+		if duration > p.opts.MaxBanDuration {
+			duration = p.opts.MaxBanDuration
+		}
+		expiration = now.Add(duration)
+	}
+
+	reason := fmt.Sprintf("error score %v exceeds threshold, offense %v, recent errors: %v", p.errorScore(id), offense.count, p.errorCategorySummary(id))
+
+	// The ban now carries the penalty, so the peer starts with a clean score once it expires
+	delete(p.errorScores, id)
+
+	p.AccessList.AddBan(&Ban{Peer: id, Expiration: expiration, Reason: reason, Offenses: offense.count})
+	if expiration.IsZero() {
+		log.Infof("Permanently banned peer %s: %s", id, reason)
+	} else {
+		log.Infof("Banned peer %s until %s: %s", id, expiration.Format(time.RFC3339), reason)
+	}
+	p.events.PeerBanned(id, expiration, reason)
+
+	go func() {
+		select {
+		case p.disconnectPeerChan <- id:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (p *PeerErrorHandler) isPermanentBanCategory(category options.ErrorCategory) bool {
+	for _, c := range p.opts.PermanentBanCategories {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *PeerErrorHandler) handleReward(reward PeerReward) {
+	score := p.getScoreForReward(reward.reward) * reward.count
+	if score == 0 {
+		return
+	}
+
+	if record, ok := p.reputations[reward.id]; ok {
+		p.decayScore(record, p.opts.ReputationDecayHalflife)
+		record.score += score
+	} else {
+		p.reputations[reward.id] = &errorScoreRecord{
+			lastUpdate: time.Now(),
+			score:      score,
+		}
+	}
+
+	if p.reputations[reward.id].score > p.opts.MaxReputation {
+		p.reputations[reward.id].score = p.opts.MaxReputation
+	}
+}
+
+func (p *PeerErrorHandler) getScoreForReward(reward Reward) uint64 {
+	switch reward {
+	case SyncBlockReward:
+		return p.opts.SyncBlockReputation
+	case GossipBlockReward:
+		return p.opts.GossipBlockReputation
+	case LowLatencyReward:
+		return p.opts.LowLatencyReputation
+	default:
+		return 0
+	}
+}
+
+func (p *PeerErrorHandler) reputation(id peer.ID) uint64 {
+	if record, ok := p.reputations[id]; ok {
+		p.decayScore(record, p.opts.ReputationDecayHalflife)
+		return record.score
+	}
+
+	return 0
+}
+
+// decayPeerErrorScores decays each of the peer's error category scores by its half-life
+func (p *PeerErrorHandler) decayPeerErrorScores(id peer.ID) {
+	for category, record := range p.errorScores[id] {
+		halflife := p.opts.ErrorScores[category].Halflife
+		if halflife == 0 {
+			halflife = p.opts.ErrorScoreDecayHalflife
+		}
+		p.decayScore(record, halflife)
+	}
+}
+
+// errorScore is the peer's total error score as of the last decay
+func (p *PeerErrorHandler) errorScore(id peer.ID) uint64 {
+	var score uint64
+	for _, record := range p.errorScores[id] {
+		score += record.score
+	}
+
+	return score
+}
+
+// exceedsErrorThreshold returns if the peer's total error score, offset by its reputation, exceeds the
+// error score threshold, or if the score of any category with its own threshold exceeds that threshold
+func (p *PeerErrorHandler) exceedsErrorThreshold(id peer.ID) bool {
+	for category, record := range p.errorScores[id] {
+		threshold := p.opts.ErrorScores[category].Threshold
+		if threshold > 0 && record.score >= threshold {
+			return true
+		}
+	}
+
+	return p.offsetErrorScore(id, p.errorScore(id)) >= p.opts.ErrorScoreThreshold
+}
+
+// offsetErrorScore offsets an error score by the peer's reputation, up to the maximum offset
+func (p *PeerErrorHandler) offsetErrorScore(id peer.ID, score uint64) uint64 {
+	offset := p.reputation(id)
+	if offset > p.opts.MaxReputationOffset {
+		offset = p.opts.MaxReputationOffset
+	}
+
+	if offset >= score {
+		return 0
+	}
+
+	return score - offset
+}
+
+func (p *PeerErrorHandler) handleGetReputations(ids []peer.ID) map[peer.ID]int64 {
+	reputations := make(map[peer.ID]int64, len(ids))
+	for _, id := range ids {
+		p.decayPeerErrorScores(id)
+		reputations[id] = int64(p.reputation(id)) - int64(p.errorScore(id))
+	}
+
+	return reputations
+}
+
+// GetReputations returns the reputation of each peer, net of its error score, satisfying the ReputationProvider interface
+func (p *PeerErrorHandler) GetReputations(ctx context.Context, ids []peer.ID) map[peer.ID]int64 {
+	resultChan := make(chan map[peer.ID]int64, 1)
+	select {
+	case p.reputationChan <- reputationRequest{ids: ids, resultChan: resultChan}:
+	case <-ctx.Done():
+		return nil
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (p *PeerErrorHandler) recordError(id peer.ID, record PeerErrorRecord) {
+	history, ok := p.errorHistories[id]
+	if !ok {
+		// Forget the peer whose last error is the oldest to bound the number of histories kept
+		if uint64(len(p.errorHistories)) >= p.opts.ErrorHistoryMaxPeers {
+			var oldest peer.ID
+			for pid, h := range p.errorHistories {
+				if oldest == "" || h.last().Before(p.errorHistories[oldest].last()) {
+					oldest = pid
+				}
 			}
-		}()
+			delete(p.errorHistories, oldest)
+		}
+
+		history = newErrorHistory(p.opts.ErrorHistorySize)
+		p.errorHistories[id] = history
+	}
+
+	history.add(record)
+}
+
+// errorCategorySummary counts the peer's recent errors by category
+func (p *PeerErrorHandler) errorCategorySummary(id peer.ID) map[options.ErrorCategory]int {
+	summary := make(map[options.ErrorCategory]int)
+	if history, ok := p.errorHistories[id]; ok {
+		for _, record := range history.list() {
+			summary[record.Category]++
+		}
+	}
+
+	return summary
+}
+
+func (p *PeerErrorHandler) handleGetErrorHistory(id peer.ID) map[peer.ID][]PeerErrorRecord {
+	histories := make(map[peer.ID][]PeerErrorRecord)
+	for pid, history := range p.errorHistories {
+		if id == "" || id == pid {
+			histories[pid] = history.list()
+		}
+	}
+
+	return histories
+}
+
+// GetErrorHistory returns the recent errors of a peer, from oldest to newest
+func (p *PeerErrorHandler) GetErrorHistory(ctx context.Context, id peer.ID) []PeerErrorRecord {
+	return p.GetErrorHistories(ctx, id)[id]
+}
+
+// GetErrorHistories returns the recent errors of all peers with an error history.
+// If id is not empty, only the history of that peer is returned.
+func (p *PeerErrorHandler) GetErrorHistories(ctx context.Context, id peer.ID) map[peer.ID][]PeerErrorRecord {
+	resultChan := make(chan map[peer.ID][]PeerErrorRecord, 1)
+	select {
+	case p.errorHistoryChan <- errorHistoryRequest{id: id, resultChan: resultChan}:
+	case <-ctx.Done():
+		return nil
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-ctx.Done():
+		return nil
 	}
 }
 
-func (p *PeerErrorHandler) getScoreForError(err error) uint64 {
+func (p *PeerErrorHandler) getCategoryForError(err error) options.ErrorCategory {
 	// These should be ordered from most common error to least
 	switch {
 
 	// Errors that are commonly expected during normal use or potential attack vectors
 	case errors.Is(err, p2perrors.ErrTransactionApplication):
-		return p.opts.TransactionApplicationErrorScore
+		return options.TransactionApplicationError
+	case errors.Is(err, p2perrors.ErrTransactionRateLimit):
+		return options.TransactionRateLimitError
+	case errors.Is(err, p2perrors.ErrUnknownPreviousBlock):
+		return options.UnknownPreviousBlockError
+	case errors.Is(err, p2perrors.ErrDuplicateBlock):
+		return options.DuplicateBlockError
+	case errors.Is(err, p2perrors.ErrInvalidBlockTransaction):
+		return options.InvalidBlockTransactionError
+	case errors.Is(err, p2perrors.ErrBlockResourceLimit):
+		return options.BlockResourceLimitError
+	case errors.Is(err, p2perrors.ErrInvalidBlockSignature):
+		return options.InvalidBlockSignatureError
 	case errors.Is(err, p2perrors.ErrBlockApplication):
-		return p.opts.BlockApplicationErrorScore
+		return options.BlockApplicationError
 	case errors.Is(err, p2perrors.ErrDeserialization):
-		return p.opts.DeserializationErrorScore
+		return options.DeserializationError
 	case errors.Is(err, p2perrors.ErrBlockIrreversibility):
-		return p.opts.BlockIrreversibilityErrorScore
+		return options.BlockIrreversibilityError
 	case errors.Is(err, p2perrors.ErrPeerRPC):
-		return p.opts.PeerRPCErrorScore
+		return options.PeerRPCError
 	case errors.Is(err, p2perrors.ErrPeerRPCTimeout):
-		return p.opts.PeerRPCTimeoutErrorScore
+		return options.PeerRPCTimeoutError
 
 	// These errors are expected, but result in instant disconnection
 	case errors.Is(err, p2perrors.ErrChainIDMismatch):
-		return p.opts.ChainIDMismatchErrorScore
+		return options.ChainIDMismatchError
 	case errors.Is(err, p2perrors.ErrChainNotConnected):
-		return p.opts.ChainNotConnectedErrorScore
+		return options.ChainNotConnectedError
 	case errors.Is(err, p2perrors.ErrCheckpointMismatch):
-		return p.opts.CheckpointMismatchErrorScore
+		return options.CheckpointMismatchError
 
 	// Errors that should only originate from the local process or local node
 	case errors.Is(err, p2perrors.ErrLocalRPC):
-		return p.opts.LocalRPCErrorScore
+		return options.LocalRPCError
 	case errors.Is(err, p2perrors.ErrLocalRPCTimeout):
-		return p.opts.LocalRPCTimeoutErrorScore
+		return options.LocalRPCTimeoutError
 	case errors.Is(err, p2perrors.ErrSerialization):
-		return p.opts.SerializationErrorScore
+		return options.SerializationError
 	case errors.Is(err, p2perrors.ErrProcessRequestTimeout):
-		return p.opts.ProcessRequestTimeoutErrorScore
+		return options.ProcessRequestTimeoutError
 
 	default:
-		return p.opts.UnknownErrorScore
+		return options.UnknownError
 	}
 }
 
 func (p *PeerErrorHandler) decayErrorScore(record *errorScoreRecord) {
-	decayConstant := math.Log(2) / float64(p.opts.ErrorScoreDecayHalflife)
+	p.decayScore(record, p.opts.ErrorScoreDecayHalflife)
+}
+
+func (p *PeerErrorHandler) decayScore(record *errorScoreRecord, halflife time.Duration) {
+	decayConstant := math.Log(2) / float64(halflife)
 	now := time.Now()
 	record.score = uint64(float64(record.score) * math.Exp(-1*decayConstant*float64(now.Sub(record.lastUpdate))))
 	record.lastUpdate = now
 }
 
+// BanPeer manually bans a peer, disconnecting from it if connected.
+// A zero expiration bans the peer permanently.
+func (p *PeerErrorHandler) BanPeer(ctx context.Context, id peer.ID, expiration time.Time, reason string) {
+	p.AccessList.AddBan(&Ban{Peer: id, Expiration: expiration, Reason: reason})
+	log.Infof("Banned peer %s: %s", id, reason)
+	p.events.PeerBanned(id, expiration, reason)
+
+	go func() {
+		select {
+		case p.disconnectPeerChan <- id:
+		case <-ctx.Done():
+		}
+	}()
+}
+
 // InterceptPeerDial implements the libp2p ConnectionGater interface
 func (p *PeerErrorHandler) InterceptPeerDial(pid peer.ID) bool {
+	if p.AccessList.IsAllowed(pid) {
+		return true
+	}
+
+	if p.opts.AllowOnly {
+		return false
+	}
+
+	if p.AccessList.IsPeerBanned(pid) {
+		return false
+	}
+
 	return p.CanConnect(context.Background(), pid)
 }
 
 // InterceptAddrDial implements the libp2p ConnectionGater interface
-func (p *PeerErrorHandler) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool {
-	return true
+func (p *PeerErrorHandler) InterceptAddrDial(pid peer.ID, addr multiaddr.Multiaddr) bool {
+	if p.AccessList.IsAllowed(pid) {
+		return true
+	}
+
+	if p.opts.AllowOnly {
+		return false
+	}
+
+	if p.AccessList.IsAddressBanned(addr) {
+		return false
+	}
+
+	return p.CanConnectAddr(context.Background(), pid, addr)
 }
 
 // InterceptAccept implements the libp2p ConnectionGater interface
-func (p *PeerErrorHandler) InterceptAccept(network.ConnMultiaddrs) bool {
-	return true
+//
+// The remote peer ID is not yet known, so address bans and limits apply even to allowed peers,
+// and peers that are not allowed are only rejected once secured.
+func (p *PeerErrorHandler) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	if p.AccessList.IsAddressBanned(addrs.RemoteMultiaddr()) {
+		return false
+	}
+
+	return p.CanAccept(context.Background(), addrs.RemoteMultiaddr())
 }
 
 // InterceptSecured implements the libp2p ConnectionGater interface
-func (p *PeerErrorHandler) InterceptSecured(_ network.Direction, pid peer.ID, _ network.ConnMultiaddrs) bool {
-	return p.CanConnect(context.Background(), pid)
+func (p *PeerErrorHandler) InterceptSecured(_ network.Direction, pid peer.ID, addrs network.ConnMultiaddrs) bool {
+	if p.AccessList.IsAllowed(pid) {
+		return true
+	}
+
+	if p.opts.AllowOnly {
+		return false
+	}
+
+	if p.AccessList.IsPeerBanned(pid) || p.AccessList.IsAddressBanned(addrs.RemoteMultiaddr()) {
+		return false
+	}
+
+	return p.CanConnectAddr(context.Background(), pid, addrs.RemoteMultiaddr())
 }
 
 // InterceptUpgraded implements the libp2p ConnectionGater interface
@@ -163,6 +710,38 @@ func (p *PeerErrorHandler) InterceptUpgraded(network.Conn) (bool, control.Discon
 	return true, 0
 }
 
+// OpenedStream is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) OpenedStream(n network.Network, s network.Stream) {
+}
+
+// ClosedStream is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) ClosedStream(n network.Network, s network.Stream) {
+}
+
+// Connected is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) Connected(net network.Network, conn network.Conn) {
+	select {
+	case p.connectionChan <- connectionEvent{id: conn.RemotePeer(), addr: conn.RemoteMultiaddr(), connected: true}:
+	case <-p.done:
+	}
+}
+
+// Disconnected is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) Disconnected(net network.Network, conn network.Conn) {
+	select {
+	case p.connectionChan <- connectionEvent{id: conn.RemotePeer(), addr: conn.RemoteMultiaddr(), connected: false}:
+	case <-p.done:
+	}
+}
+
+// Listen is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) Listen(n network.Network, _ multiaddr.Multiaddr) {
+}
+
+// ListenClose is part of the libp2p network.Notifiee interface
+func (p *PeerErrorHandler) ListenClose(n network.Network, _ multiaddr.Multiaddr) {
+}
+
 // Start processing peer errors
 func (p *PeerErrorHandler) Start(ctx context.Context) {
 	go func() {
@@ -170,10 +749,19 @@ func (p *PeerErrorHandler) Start(ctx context.Context) {
 			select {
 			case perr := <-p.peerErrorChan:
 				p.handleError(ctx, perr)
+			case reward := <-p.peerRewardChan:
+				p.handleReward(reward)
+			case req := <-p.reputationChan:
+				req.resultChan <- p.handleGetReputations(req.ids)
+			case req := <-p.errorHistoryChan:
+				req.resultChan <- p.handleGetErrorHistory(req.id)
 			case req := <-p.canConnectChan:
-				req.resultChan <- p.handleCanConnect(req.id)
+				req.resultChan <- p.handleCanConnect(req)
+			case event := <-p.connectionChan:
+				p.handleConnectionEvent(event)
 
 			case <-ctx.Done():
+				close(p.done)
 				return
 			}
 		}
@@ -181,12 +769,26 @@ func (p *PeerErrorHandler) Start(ctx context.Context) {
 }
 
 // NewPeerErrorHandler creates a new PeerErrorHandler
-func NewPeerErrorHandler(disconnectPeerChan chan<- peer.ID, peerErrorChan <-chan PeerError, opts options.PeerErrorHandlerOptions) *PeerErrorHandler {
+func NewPeerErrorHandler(disconnectPeerChan chan<- peer.ID, peerErrorChan <-chan PeerError, peerRewardChan <-chan PeerReward, events *EventPublisher, opts options.PeerErrorHandlerOptions) *PeerErrorHandler {
 	return &PeerErrorHandler{
-		errorScores:        make(map[peer.ID]*errorScoreRecord),
+		errorScores:        make(map[peer.ID]map[options.ErrorCategory]*errorScoreRecord),
+		reputations:        make(map[peer.ID]*errorScoreRecord),
+		errorHistories:     make(map[peer.ID]*errorHistory),
+		offenses:           make(map[peer.ID]*offenseRecord),
+		ipErrorScores:      make(map[string]*errorScoreRecord),
+		ipConnections:      make(map[string]int),
+		subnetConnections:  make(map[string]int),
+		peerIPs:            make(map[peer.ID]map[string]int),
 		disconnectPeerChan: disconnectPeerChan,
 		peerErrorChan:      peerErrorChan,
+		peerRewardChan:     peerRewardChan,
 		canConnectChan:     make(chan canConnectRequest),
+		reputationChan:     make(chan reputationRequest),
+		errorHistoryChan:   make(chan errorHistoryRequest),
+		connectionChan:     make(chan connectionEvent),
+		done:               make(chan struct{}),
+		AccessList:         NewAccessList(opts),
+		events:             events,
 		opts:               opts,
 	}
 }
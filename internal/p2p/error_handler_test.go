@@ -2,12 +2,16 @@ package p2p
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/koinos/koinos-p2p/internal/options"
 	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
 )
 
 func TestErrorHandler(t *testing.T) {
@@ -16,11 +20,12 @@ func TestErrorHandler(t *testing.T) {
 	opts := options.NewPeerErrorHandlerOptions()
 	ctx := context.Background()
 
-	opts.BlockApplicationErrorScore = 10
+	opts.ErrorScores[options.BlockApplicationError] = options.ErrorScoreOptions{Score: 10}
 	opts.ErrorScoreThreshold = 100
 	opts.ErrorScoreDecayHalflife = time.Second * 2
+	opts.BanDuration = time.Millisecond * 250
 
-	errorHandler := NewPeerErrorHandler(disconnectPeerChan, peerErrorChan, *opts)
+	errorHandler := NewPeerErrorHandler(disconnectPeerChan, peerErrorChan, make(chan PeerReward), nil, *opts)
 	errorHandler.Start(ctx)
 
 	for i := 0; i < 12; i++ {
@@ -71,3 +76,349 @@ func TestErrorHandler(t *testing.T) {
 		t.Errorf("Expected failed connection to peerA")
 	}
 }
+
+func TestBlockApplicationErrorCategories(t *testing.T) {
+	opts := options.NewPeerErrorHandlerOptions()
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), make(chan PeerError), make(chan PeerReward), nil, *opts)
+
+	cases := []struct {
+		err      error
+		category options.ErrorCategory
+	}{
+		{fmt.Errorf("%w - block", p2perrors.ErrUnknownPreviousBlock), options.UnknownPreviousBlockError},
+		{fmt.Errorf("%w - block", p2perrors.ErrInvalidBlockSignature), options.InvalidBlockSignatureError},
+		{fmt.Errorf("%w - block", p2perrors.ErrDuplicateBlock), options.DuplicateBlockError},
+		{fmt.Errorf("%w - block", p2perrors.ErrInvalidBlockTransaction), options.InvalidBlockTransactionError},
+		{fmt.Errorf("%w - block", p2perrors.ErrBlockResourceLimit), options.BlockResourceLimitError},
+		{fmt.Errorf("%w - block", p2perrors.ErrBlockApplication), options.BlockApplicationError},
+	}
+
+	for _, c := range cases {
+		if !errors.Is(c.err, p2perrors.ErrBlockApplication) {
+			t.Errorf("Error %s is not a block application error", c.err)
+		}
+
+		if category := errorHandler.getCategoryForError(c.err); category != c.category {
+			t.Errorf("Incorrect category for error %s. Expected %v, was %v", c.err, c.category, category)
+		}
+	}
+}
+
+func TestIPConnectionGating(t *testing.T) {
+	peerErrorChan := make(chan PeerError)
+	opts := options.NewPeerErrorHandlerOptions()
+	ctx := context.Background()
+
+	opts.ErrorScores[options.BlockApplicationError] = options.ErrorScoreOptions{Score: 10}
+	opts.ErrorScoreThreshold = 100
+	opts.IPErrorScoreThreshold = 100
+	opts.MaxConnectionsPerIP = 2
+	opts.MaxConnectionsPerSubnet = 3
+
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), peerErrorChan, make(chan PeerReward), nil, *opts)
+	errorHandler.Start(ctx)
+
+	hostA := multiaddr.StringCast("/ip4/10.0.0.1/tcp/8888")
+	hostB := multiaddr.StringCast("/ip4/10.0.0.2/tcp/8888")
+	hostC := multiaddr.StringCast("/ip4/10.0.1.1/tcp/8888")
+
+	errorHandler.connectionChan <- connectionEvent{id: "peerA", addr: hostA, connected: true}
+	errorHandler.connectionChan <- connectionEvent{id: "peerB", addr: hostA, connected: true}
+
+	if errorHandler.InterceptAccept(&testConnMultiaddrs{hostA}) {
+		t.Errorf("Expected failed accept from IP address at connection limit")
+	}
+
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{hostB}) {
+		t.Errorf("Expected successful accept from IP address below connection limit")
+	}
+
+	errorHandler.connectionChan <- connectionEvent{id: "peerC", addr: hostB, connected: true}
+
+	if errorHandler.InterceptAccept(&testConnMultiaddrs{hostB}) {
+		t.Errorf("Expected failed accept from subnet at connection limit")
+	}
+
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{hostC}) {
+		t.Errorf("Expected successful accept from a different subnet")
+	}
+
+	errorHandler.connectionChan <- connectionEvent{id: "peerB", addr: hostA, connected: false}
+
+	if !errorHandler.InterceptAccept(&testConnMultiaddrs{hostA}) {
+		t.Errorf("Expected successful accept after a connection was closed")
+	}
+
+	// Errors from peerA count against its IP address, blocking fresh peer IDs from the same host
+	for i := 0; i < 12; i++ {
+		peerErrorChan <- PeerError{id: "peerA", err: p2perrors.ErrBlockApplication}
+	}
+
+	errorHandler.connectionChan <- connectionEvent{id: "peerA", addr: hostA, connected: false}
+
+	if errorHandler.InterceptAccept(&testConnMultiaddrs{hostA}) {
+		t.Errorf("Expected failed accept from IP address above error score threshold")
+	}
+
+	if errorHandler.InterceptSecured(network.DirInbound, "peerD", &testConnMultiaddrs{hostA}) {
+		t.Errorf("Expected failed secured connection from IP address above error score threshold")
+	}
+
+	if !errorHandler.InterceptSecured(network.DirInbound, "peerD", &testConnMultiaddrs{hostC}) {
+		t.Errorf("Expected successful secured connection from a different IP address")
+	}
+}
+
+func TestIPErrorScorePruning(t *testing.T) {
+	opts := options.NewPeerErrorHandlerOptions()
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), make(chan PeerError), make(chan PeerReward), nil, *opts)
+
+	// One score has fully decayed, the other has not
+	errorHandler.ipErrorScores["10.0.0.1"] = &errorScoreRecord{lastUpdate: time.Now().Add(-100 * opts.ErrorScoreDecayHalflife), score: 1000}
+	errorHandler.ipErrorScores["10.0.0.2"] = &errorScoreRecord{lastUpdate: time.Now(), score: 1000}
+
+	errorHandler.pruneIPErrorScores()
+
+	if _, ok := errorHandler.ipErrorScores["10.0.0.1"]; ok {
+		t.Errorf("Expected decayed IP error score to be pruned")
+	}
+
+	if _, ok := errorHandler.ipErrorScores["10.0.0.2"]; !ok {
+		t.Errorf("Expected IP error score to be kept")
+	}
+
+	// Pruning is rate limited
+	errorHandler.ipErrorScores["10.0.0.1"] = &errorScoreRecord{lastUpdate: time.Now().Add(-100 * opts.ErrorScoreDecayHalflife), score: 1000}
+	errorHandler.pruneIPErrorScores()
+
+	if _, ok := errorHandler.ipErrorScores["10.0.0.1"]; !ok {
+		t.Errorf("Expected IP error scores not to be pruned again within the prune interval")
+	}
+}
+
+func TestPeerReputation(t *testing.T) {
+	disconnectPeerChan := make(chan peer.ID)
+	peerErrorChan := make(chan PeerError)
+	peerRewardChan := make(chan PeerReward)
+	opts := options.NewPeerErrorHandlerOptions()
+	ctx := context.Background()
+
+	opts.ErrorScores[options.BlockApplicationError] = options.ErrorScoreOptions{Score: 10}
+	opts.ErrorScoreThreshold = 100
+	opts.MaxReputation = 1000
+	opts.MaxReputationOffset = 50
+
+	errorHandler := NewPeerErrorHandler(disconnectPeerChan, peerErrorChan, peerRewardChan, nil, *opts)
+	errorHandler.Start(ctx)
+
+	// peerA has served many blocks, peerB is new
+	peerRewardChan <- PeerReward{id: "peerA", reward: SyncBlockReward, count: 5000}
+	peerRewardChan <- PeerReward{id: "peerA", reward: GossipBlockReward, count: 1}
+
+	reputations := errorHandler.GetReputations(ctx, []peer.ID{"peerA", "peerB"})
+	if reputations["peerA"] < 990 || reputations["peerA"] > int64(opts.MaxReputation) {
+		t.Errorf("Incorrect reputation for peerA. Expected %v, was %v", opts.MaxReputation, reputations["peerA"])
+	}
+
+	if reputations["peerB"] != 0 {
+		t.Errorf("Incorrect reputation for peerB. Expected 0, was %v", reputations["peerB"])
+	}
+
+	// Reputation offsets errors up to the maximum offset
+	for i := 0; i < 12; i++ {
+		peerErrorChan <- PeerError{id: "peerA", err: p2perrors.ErrBlockApplication}
+		peerErrorChan <- PeerError{id: "peerB", err: p2perrors.ErrBlockApplication}
+	}
+
+	if !errorHandler.CanConnect(ctx, "peerA") {
+		t.Errorf("Expected successful connection to peerA")
+	}
+
+	if errorHandler.CanConnect(ctx, "peerB") {
+		t.Errorf("Expected failed connection to peerB")
+	}
+
+	exp, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	select {
+	case peer := <-disconnectPeerChan:
+		if peer != "peerB" {
+			t.Errorf("Incorrect peer requested for disconnect. Expected: peerB, Was %s", peer)
+		}
+	case <-exp.Done():
+		t.Errorf("Expected request to disconnect from peerB never received")
+	}
+
+	for i := 0; i < 6; i++ {
+		peerErrorChan <- PeerError{id: "peerA", err: p2perrors.ErrBlockApplication}
+	}
+
+	if errorHandler.CanConnect(ctx, "peerA") {
+		t.Errorf("Expected failed connection to peerA")
+	}
+
+	reputations = errorHandler.GetReputations(ctx, []peer.ID{"peerA", "peerB"})
+	if reputations["peerA"] <= reputations["peerB"] {
+		t.Errorf("Expected peerA to have a higher reputation than peerB")
+	}
+}
+
+func TestErrorCategoryScores(t *testing.T) {
+	disconnectPeerChan := make(chan peer.ID)
+	peerErrorChan := make(chan PeerError)
+	opts := options.NewPeerErrorHandlerOptions()
+	ctx := context.Background()
+
+	opts.ErrorScoreThreshold = 100
+	opts.ErrorScoreDecayHalflife = time.Hour
+
+	err := options.ParseErrorScores(map[interface{}]interface{}{
+		"peer-rpc-timeout": map[interface{}]interface{}{
+			"score":    10,
+			"halflife": "100ms",
+		},
+		"checkpoint-mismatch": map[interface{}]interface{}{
+			"score":     10,
+			"threshold": 25,
+		},
+	}, opts.ErrorScores)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if opts.ErrorScores[options.PeerRPCTimeoutError].Halflife != time.Millisecond*100 {
+		t.Errorf("Error category half-life was not parsed correctly")
+	}
+
+	if options.ParseErrorScores(map[string]interface{}{"not-a-category": map[string]interface{}{}}, opts.ErrorScores) == nil {
+		t.Errorf("Unknown error category should give an error, but it did not")
+	}
+
+	// A table with any invalid entry changes no scores
+	err = options.ParseErrorScores(map[string]interface{}{
+		"peer-rpc":         map[string]interface{}{"score": 1},
+		"local-rpc":        map[string]interface{}{"score": -1},
+		"unknown":          map[string]interface{}{"score": 1},
+		"peer-rpc-timeout": map[string]interface{}{"halflife": "-1s"},
+	}, opts.ErrorScores)
+	if err == nil {
+		t.Errorf("Invalid error score table should give an error, but it did not")
+	}
+
+	if opts.ErrorScores[options.PeerRPCError].Score != 1000 || opts.ErrorScores[options.UnknownError].Score != 5000 {
+		t.Errorf("Invalid error score table should not change any scores")
+	}
+
+	errorHandler := NewPeerErrorHandler(disconnectPeerChan, peerErrorChan, make(chan PeerReward), nil, *opts)
+	errorHandler.Start(ctx)
+
+	// A category threshold applies regardless of the total score
+	for i := 0; i < 2; i++ {
+		peerErrorChan <- PeerError{id: "peerB", err: p2perrors.ErrCheckpointMismatch}
+	}
+
+	if !errorHandler.CanConnect(ctx, "peerB") {
+		t.Errorf("Expected successful connection to peerB")
+	}
+
+	peerErrorChan <- PeerError{id: "peerB", err: p2perrors.ErrCheckpointMismatch}
+
+	exp, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	select {
+	case peer := <-disconnectPeerChan:
+		if peer != "peerB" {
+			t.Errorf("Incorrect peer requested for disconnect. Expected: peerB, Was %s", peer)
+		}
+	case <-exp.Done():
+		t.Errorf("Expected request to disconnect from peerB never received")
+	}
+
+	if errorHandler.CanConnect(ctx, "peerB") {
+		t.Errorf("Expected failed connection to peerB")
+	}
+
+	// Transient errors decay on their own, faster half-life
+	for i := 0; i < 8; i++ {
+		peerErrorChan <- PeerError{id: "peerA", err: p2perrors.ErrPeerRPCTimeout}
+	}
+
+	if reputation := errorHandler.GetReputations(ctx, []peer.ID{"peerA"})["peerA"]; reputation > -70 {
+		t.Errorf("Expected peerA error score of about 80, was %v", -reputation)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	if reputation := errorHandler.GetReputations(ctx, []peer.ID{"peerA"})["peerA"]; reputation < -20 {
+		t.Errorf("Expected peerA error score to decay below 20, was %v", -reputation)
+	}
+}
+
+func TestEscalatingBans(t *testing.T) {
+	disconnectPeerChan := make(chan peer.ID, 16)
+	peerErrorChan := make(chan PeerError)
+	opts := options.NewPeerErrorHandlerOptions()
+	ctx := context.Background()
+
+	opts.ErrorScoreThreshold = 100
+	opts.ErrorScores[options.BlockApplicationError] = options.ErrorScoreOptions{Score: 100}
+	opts.BanDuration = time.Millisecond * 100
+	opts.BanEscalationFactor = 4
+	opts.MaxBanDuration = time.Second
+
+	errorHandler := NewPeerErrorHandler(disconnectPeerChan, peerErrorChan, make(chan PeerReward), nil, *opts)
+	errorHandler.Start(ctx)
+
+	peerA, _ := peer.Decode("QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N")
+
+	// The first offense results in a short ban
+	peerErrorChan <- PeerError{id: peerA, err: p2perrors.ErrBlockApplication}
+
+	if errorHandler.CanConnect(ctx, peerA) || <-disconnectPeerChan != peerA {
+		t.Errorf("Expected peerA to be banned and disconnected")
+	}
+
+	ban, banned := errorHandler.AccessList.GetPeerBan(peerA)
+	if !banned || ban.Offenses != 1 || time.Until(ban.Expiration) > opts.BanDuration {
+		t.Errorf("Incorrect ban for first offense: %v", ban)
+	}
+
+	time.Sleep(time.Millisecond * 150)
+
+	if !errorHandler.CanConnect(ctx, peerA) {
+		t.Errorf("Expected successful connection to peerA after ban expired")
+	}
+
+	// Repeated offenses escalate the ban duration, up to the maximum
+	peerErrorChan <- PeerError{id: peerA, err: p2perrors.ErrBlockApplication}
+	if errorHandler.CanConnect(ctx, peerA) {
+		t.Errorf("Expected failed connection to peerA")
+	}
+
+	ban, _ = errorHandler.AccessList.GetPeerBan(peerA)
+	if ban.Offenses != 2 || time.Until(ban.Expiration) < opts.BanDuration*3 {
+		t.Errorf("Incorrect ban for second offense: %v", ban)
+	}
+
+	errorHandler.AccessList.RemoveBan(peerA.Pretty())
+	peerErrorChan <- PeerError{id: peerA, err: p2perrors.ErrBlockApplication}
+	if errorHandler.CanConnect(ctx, peerA) {
+		t.Errorf("Expected failed connection to peerA")
+	}
+
+	ban, _ = errorHandler.AccessList.GetPeerBan(peerA)
+	if ban.Offenses != 3 || time.Until(ban.Expiration) > opts.MaxBanDuration {
+		t.Errorf("Incorrect ban for third offense: %v", ban)
+	}
+
+	// Chain ID mismatches result in a permanent ban
+	peerErrorChan <- PeerError{id: "peerB", err: p2perrors.ErrChainIDMismatch}
+	if errorHandler.CanConnect(ctx, "peerB") {
+		t.Errorf("Expected failed connection to peerB")
+	}
+
+	ban, banned = errorHandler.AccessList.GetPeerBan("peerB")
+	if !banned || !ban.Expiration.IsZero() {
+		t.Errorf("Expected peerB to be banned permanently: %v", ban)
+	}
+}
@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+)
+
+// PeerErrorRecord is an entry in a peer's error history
+type PeerErrorRecord struct {
+	Time       time.Time             `json:"time"`
+	Category   options.ErrorCategory `json:"category"`
+	Message    string                `json:"message"`
+	ScoreDelta uint64                `json:"score_delta"`
+}
+
+// errorHistory is a fixed size ring buffer of a peer's most recent errors
+type errorHistory struct {
+	records []PeerErrorRecord
+	next    int
+	full    bool
+}
+
+func newErrorHistory(size uint64) *errorHistory {
+	return &errorHistory{
+		records: make([]PeerErrorRecord, size),
+	}
+}
+
+func (h *errorHistory) add(record PeerErrorRecord) {
+	if len(h.records) == 0 {
+		return
+	}
+
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the records from oldest to newest
+func (h *errorHistory) list() []PeerErrorRecord {
+	if !h.full {
+		return append([]PeerErrorRecord(nil), h.records[:h.next]...)
+	}
+
+	records := make([]PeerErrorRecord, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+	return append(records, h.records[:h.next]...)
+}
+
+func (h *errorHistory) last() time.Time {
+	if h.next == 0 && !h.full {
+		return time.Time{}
+	}
+
+	return h.records[(h.next+len(h.records)-1)%len(h.records)].Time
+}
@@ -0,0 +1,116 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/events"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// Broadcast topics of p2p events
+const (
+	PeerConnectedTopic    = "koinos.p2p.peer_connected"
+	PeerDisconnectedTopic = "koinos.p2p.peer_disconnected"
+	PeerHandshakedTopic   = "koinos.p2p.peer_handshaked"
+	PeerBannedTopic       = "koinos.p2p.peer_banned"
+	GossipStatusTopic     = "koinos.p2p.gossip_status"
+)
+
+const eventQueueSize = 64
+
+type event struct {
+	topic   string
+	message proto.Message
+}
+
+// EventPublisher broadcasts p2p state changes to other microservices.
+// Events are published in order from a queue, so callers never block on the message queue.
+// A nil EventPublisher drops all events.
+type EventPublisher struct {
+	publisher rpc.Publisher
+	eventChan chan event
+}
+
+// NewEventPublisher creates an EventPublisher. Returns nil if publisher is nil.
+func NewEventPublisher(publisher rpc.Publisher) *EventPublisher {
+	if publisher == nil {
+		return nil
+	}
+
+	return &EventPublisher{
+		publisher: publisher,
+		eventChan: make(chan event, eventQueueSize),
+	}
+}
+
+func (e *EventPublisher) publish(topic string, message proto.Message) {
+	if e == nil {
+		return
+	}
+
+	select {
+	case e.eventChan <- event{topic: topic, message: message}:
+	default:
+		log.Warnf("Event queue is full, dropping %s event", topic)
+	}
+}
+
+// PeerConnected publishes that a peer connected
+func (e *EventPublisher) PeerConnected(id peer.ID, address string) {
+	e.publish(PeerConnectedTopic, &events.PeerConnected{PeerId: id.Pretty(), Address: address})
+}
+
+// PeerDisconnected publishes that a peer disconnected
+func (e *EventPublisher) PeerDisconnected(id peer.ID, address string) {
+	e.publish(PeerDisconnectedTopic, &events.PeerDisconnected{PeerId: id.Pretty(), Address: address})
+}
+
+// PeerHandshaked publishes that the handshake with a peer succeeded
+func (e *EventPublisher) PeerHandshaked(id peer.ID) {
+	e.publish(PeerHandshakedTopic, &events.PeerHandshaked{PeerId: id.Pretty()})
+}
+
+// PeerBanned publishes that a peer was banned. A zero expiration is a permanent ban.
+func (e *EventPublisher) PeerBanned(id peer.ID, expiration time.Time, reason string) {
+	banned := &events.PeerBanned{PeerId: id.Pretty(), Reason: reason}
+	if !expiration.IsZero() {
+		banned.Expiration = uint64(expiration.UnixNano() / int64(time.Millisecond))
+	}
+
+	e.publish(PeerBannedTopic, banned)
+}
+
+// GossipStatus publishes that gossip was enabled or disabled
+func (e *EventPublisher) GossipStatus(enabled bool) {
+	e.publish(GossipStatusTopic, &events.GossipStatus{Enabled: enabled})
+}
+
+// Start publishing events
+func (e *EventPublisher) Start(ctx context.Context) {
+	if e == nil {
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case ev := <-e.eventChan:
+				data, err := proto.Marshal(ev.message)
+				if err != nil {
+					log.Warnf("Error serializing %s event: %s", ev.topic, err)
+					continue
+				}
+
+				if err = e.publisher.Broadcast("application/octet-stream", ev.topic, data); err != nil {
+					log.Warnf("Error publishing %s event: %s", ev.topic, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,12 +9,15 @@ import (
 	"time"
 
 	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/messages"
+	"github.com/koinos/koinos-p2p/internal/options"
 	"github.com/koinos/koinos-p2p/internal/p2perrors"
 	"github.com/koinos/koinos-p2p/internal/rpc"
 	"github.com/koinos/koinos-proto-golang/koinos/protocol"
 	util "github.com/koinos/koinos-util-golang"
 	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/multiformats/go-multihash"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -21,15 +25,30 @@ const (
 	transactionBuffer int = 32
 	blockBuffer       int = 8
 
+	// seenTransactionCacheSize is the number of recent transactions remembered to dedup the transaction topics
+	seenTransactionCacheSize int = 4096
+
 	// BlockTopicName is the block topic string
 	BlockTopicName string = "koinos.blocks"
 
 	// TransactionTopicName is the transaction topic string
 	TransactionTopicName string = "koinos.transactions"
 
+	// TransactionBatchTopicName is the topic string of transaction batches
+	TransactionBatchTopicName string = "koinos.transactions.batch"
+
 	peerAdvertiseTime time.Duration = time.Minute * 1
 )
 
+// blockApplicationError wraps an ApplyBlock error, preserving the chain's classification of the failure
+func blockApplicationError(block *protocol.Block, err error) error {
+	if errors.Is(err, p2perrors.ErrBlockApplication) {
+		return fmt.Errorf("%w - %s", err, util.BlockString(block))
+	}
+
+	return fmt.Errorf("%w - %s, %v", p2perrors.ErrBlockApplication, util.BlockString(block), err.Error())
+}
+
 // GossipManager manages gossip on a given topic
 type GossipManager struct {
 	ps            *pubsub.PubSub
@@ -107,16 +126,21 @@ func (gm *GossipManager) Stop() {
 	gm.enabled = false
 }
 
+// IsEnabled returns if gossip is enabled on this topic
+func (gm *GossipManager) IsEnabled() bool {
+	gm.enableMutex.Lock()
+	defer gm.enableMutex.Unlock()
+	return gm.enabled
+}
+
 // PublishMessage publishes the given object to this manager's topic
-func (gm *GossipManager) PublishMessage(ctx context.Context, bytes []byte) bool {
-	if !gm.enabled {
-		return false
+func (gm *GossipManager) PublishMessage(ctx context.Context, bytes []byte) error {
+	if !gm.IsEnabled() {
+		return p2perrors.ErrGossipDisabled
 	}
 
 	log.Debugf("Publishing message")
-	gm.topic.Publish(ctx, bytes)
-
-	return true
+	return gm.topic.Publish(ctx, bytes)
 }
 
 func (gm *GossipManager) readMessages(ctx context.Context, ch chan<- []byte) {
@@ -149,13 +173,21 @@ type GossipEnableHandler interface {
 
 // KoinosGossip handles gossip of blocks and transactions
 type KoinosGossip struct {
-	rpc           rpc.LocalRPC
-	Block         *GossipManager
-	Transaction   *GossipManager
-	PubSub        *pubsub.PubSub
-	PeerErrorChan chan<- PeerError
-	myPeerID      peer.ID
-	libProvider   LastIrreversibleBlockProvider
+	rpc               rpc.LocalRPC
+	Block             *GossipManager
+	Transaction       *GossipManager
+	TransactionBatch  *GossipManager
+	PubSub            *pubsub.PubSub
+	PeerErrorChan     chan<- PeerError
+	PeerRewardChan    chan<- PeerReward
+	myPeerID          peer.ID
+	libProvider       LastIrreversibleBlockProvider
+	remoteRPCProvider RemoteRPCProvider
+	orphanPool        *OrphanBlockPool
+	submissions       *PeerSubmissionHandler // Also rate limits gossiped transactions
+	batcher           *transactionBatcher    // Nil when transactions are published one per message
+	seenTransactions  *RecentIDs             // Transactions already received via either transaction topic
+	opts              *options.GossipOptions
 }
 
 // NewKoinosGossip constructs a new koinosGossip instance
@@ -164,19 +196,37 @@ func NewKoinosGossip(
 	rpc rpc.LocalRPC,
 	ps *pubsub.PubSub,
 	peerErrorChan chan<- PeerError,
+	peerRewardChan chan<- PeerReward,
 	id peer.ID,
-	libProvider LastIrreversibleBlockProvider) *KoinosGossip {
+	libProvider LastIrreversibleBlockProvider,
+	remoteRPCProvider RemoteRPCProvider,
+	orphanPool *OrphanBlockPool,
+	submissions *PeerSubmissionHandler,
+	opts *options.GossipOptions) *KoinosGossip {
 
 	block := NewGossipManager(ps, peerErrorChan, BlockTopicName)
 	transaction := NewGossipManager(ps, peerErrorChan, TransactionTopicName)
+	transactionBatch := NewGossipManager(ps, peerErrorChan, TransactionBatchTopicName)
 	kg := KoinosGossip{
-		rpc:           rpc,
-		Block:         block,
-		Transaction:   transaction,
-		PubSub:        ps,
-		PeerErrorChan: peerErrorChan,
-		myPeerID:      id,
-		libProvider:   libProvider,
+		rpc:               rpc,
+		Block:             block,
+		Transaction:       transaction,
+		TransactionBatch:  transactionBatch,
+		PubSub:            ps,
+		PeerErrorChan:     peerErrorChan,
+		PeerRewardChan:    peerRewardChan,
+		myPeerID:          id,
+		libProvider:       libProvider,
+		remoteRPCProvider: remoteRPCProvider,
+		orphanPool:        orphanPool,
+		submissions:       submissions,
+		seenTransactions:  NewRecentIDs(seenTransactionCacheSize),
+		opts:              opts,
+	}
+
+	if opts.EnableTransactionBatching {
+		kg.batcher = newTransactionBatcher(transactionBatch, opts)
+		kg.batcher.start(ctx)
 	}
 
 	return &kg
@@ -196,6 +246,12 @@ func (kg *KoinosGossip) StartGossip(ctx context.Context) {
 	log.Info("Starting gossip mode")
 	kg.startBlockGossip(ctx)
 	kg.startTransactionGossip(ctx)
+	kg.startTransactionBatchGossip(ctx)
+}
+
+// IsGossipEnabled returns if block gossip is enabled
+func (kg *KoinosGossip) IsGossipEnabled() bool {
+	return kg.Block.IsEnabled()
 }
 
 // StopGossip stops gossiping on both block and transaction topics
@@ -203,6 +259,20 @@ func (kg *KoinosGossip) StopGossip() {
 	log.Info("Stopping gossip mode")
 	kg.Block.Stop()
 	kg.Transaction.Stop()
+	kg.TransactionBatch.Stop()
+}
+
+// PublishTransaction publishes a transaction, or queues it for the next batch when batching transactions
+func (kg *KoinosGossip) PublishTransaction(ctx context.Context, data []byte) error {
+	if kg.batcher == nil {
+		return kg.Transaction.PublishMessage(ctx, data)
+	}
+
+	if !kg.TransactionBatch.IsEnabled() {
+		return p2perrors.ErrGossipDisabled
+	}
+
+	return kg.batcher.add(ctx, data)
 }
 
 func (kg *KoinosGossip) startBlockGossip(ctx context.Context) {
@@ -232,7 +302,7 @@ func (kg *KoinosGossip) startBlockGossip(ctx context.Context) {
 func (kg *KoinosGossip) validateBlock(ctx context.Context, pid peer.ID, msg *pubsub.Message) bool {
 	err := kg.applyBlock(ctx, pid, msg)
 	if err != nil {
-		if errors.Is(err, p2perrors.ErrBlockIrreversibility) {
+		if errors.Is(err, p2perrors.ErrBlockIrreversibility) || errors.Is(err, p2perrors.ErrOrphanBlock) {
 			log.Debug(err.Error())
 		} else {
 			log.Warnf("Gossiped block not applied from peer %v: %s", msg.ReceivedFrom, err)
@@ -246,6 +316,17 @@ func (kg *KoinosGossip) validateBlock(ctx context.Context, pid peer.ID, msg *pub
 
 		return false
 	}
+
+	// Pubsub only validates the first delivery of a message, so only the first peer to deliver the block is rewarded
+	if msg.ReceivedFrom != kg.myPeerID {
+		go func() {
+			select {
+			case kg.PeerRewardChan <- PeerReward{id: msg.ReceivedFrom, reward: GossipBlockReward, count: 1}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	return true
 }
 
@@ -278,13 +359,120 @@ func (kg *KoinosGossip) applyBlock(ctx context.Context, pid peer.ID, msg *pubsub
 		return p2perrors.ErrBlockIrreversibility
 	}
 
-	// TODO: Fix nil argument
+	// A block submitted directly by a peer is already applied, so only forward it. While the submitted
+	// block is being applied, wait for the result instead of applying it twice.
+	if kg.submissions.blocks.wasApplied(ctx, block.Id) {
+		log.Debugf("Gossiped block was submitted by a peer - %s", util.BlockString(block))
+		return nil
+	}
+
 	// TODO: Perhaps this block should sent to the block cache instead?
 	if _, err := kg.rpc.ApplyBlock(ctx, block); err != nil {
-		return fmt.Errorf("%w - %s, %v", p2perrors.ErrBlockApplication, util.BlockString(block), err.Error())
+		// If we do not know the previous block, attempt to fetch the missing ancestors from the peer
+		known, knownErr := kg.hasBlock(ctx, block.Header.Previous)
+		if knownErr != nil || known {
+			return blockApplicationError(block, err)
+		}
+
+		if err := kg.applyMissingBlocks(ctx, msg.ReceivedFrom, block); err != nil {
+			// Hold on to the block until its parent arrives via sync or gossip, but only when the peer could not
+			// provide the ancestors. Any other failure is the peer's fault.
+			if !errors.Is(err, p2perrors.ErrUnknownPreviousBlock) && !errors.Is(err, p2perrors.ErrBlockNotFound) {
+				return err
+			}
+
+			if kg.orphanPool.Add(block) {
+				return fmt.Errorf("%w - %s, %v", p2perrors.ErrOrphanBlock, util.BlockString(block), err.Error())
+			}
+			return err
+		}
+
+		if _, err := kg.rpc.ApplyBlock(ctx, block); err != nil {
+			return blockApplicationError(block, err)
+		}
 	}
 
 	log.Infof("Gossiped block applied - %s from peer %v", util.BlockString(block), msg.ReceivedFrom)
+
+	if err := kg.orphanPool.ApplyChildren(ctx, kg.rpc, block.Id); err != nil {
+		log.Warnf("Orphan block not applied: %s", err)
+	}
+
+	return nil
+}
+
+func (kg *KoinosGossip) hasBlock(ctx context.Context, id multihash.Multihash) (bool, error) {
+	resp, err := kg.rpc.GetBlocksByID(ctx, []multihash.Multihash{id})
+	if err != nil {
+		return false, err
+	}
+
+	if resp == nil {
+		return false, nil
+	}
+
+	for _, item := range resp.BlockItems {
+		if item.Block != nil && bytes.Equal(item.BlockId, id) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// applyMissingBlocks fetches the unknown ancestors of a block from a peer and applies them in order
+func (kg *KoinosGossip) applyMissingBlocks(ctx context.Context, pid peer.ID, block *protocol.Block) error {
+	peerRPC := kg.remoteRPCProvider.GetRemoteRPC(pid)
+	lib := kg.libProvider.GetLastIrreversibleBlock()
+	missingBlocks := make([]*protocol.Block, 0)
+	previous := multihash.Multihash(block.Header.Previous)
+
+	for {
+		if uint64(len(missingBlocks)) >= kg.opts.MissingBlockFetchDepth {
+			return fmt.Errorf("%w - %s, missing more than %v ancestor blocks", p2perrors.ErrUnknownPreviousBlock, util.BlockString(block), kg.opts.MissingBlockFetchDepth)
+		}
+
+		rpcContext, cancel := context.WithTimeout(ctx, kg.opts.MissingBlockFetchTimeout)
+		blocks, err := peerRPC.GetBlocksByID(rpcContext, []multihash.Multihash{previous})
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		if len(blocks) != 1 || !bytes.Equal(blocks[0].Id, previous) {
+			return fmt.Errorf("%w, peer did not return requested block %s", p2perrors.ErrBlockNotFound, util.MultihashString(previous))
+		}
+
+		ancestor := blocks[0]
+		if ancestor.Header == nil || ancestor.Header.Previous == nil {
+			return fmt.Errorf("%w, requested block missing header", p2perrors.ErrDeserialization)
+		}
+
+		if ancestor.Header.Height < lib.Height {
+			return p2perrors.ErrBlockIrreversibility
+		}
+
+		missingBlocks = append(missingBlocks, ancestor)
+		previous = ancestor.Header.Previous
+
+		known, err := kg.hasBlock(ctx, previous)
+		if err != nil {
+			return err
+		}
+
+		if known {
+			break
+		}
+	}
+
+	for i := len(missingBlocks) - 1; i >= 0; i-- {
+		if _, err := kg.rpc.ApplyBlock(ctx, missingBlocks[i]); err != nil {
+			return blockApplicationError(missingBlocks[i], err)
+		}
+
+		log.Infof("Missing block applied - %s from peer %v", util.BlockString(missingBlocks[i]), pid)
+	}
+
 	return nil
 }
 
@@ -312,8 +500,13 @@ func (kg *KoinosGossip) startTransactionGossip(ctx context.Context) {
 	}()
 }
 
-func (kg *KoinosGossip) validateTransaction(ctx context.Context, pid peer.ID, msg *pubsub.Message) bool {
+// validateTransaction ignores a transaction that is already known, which is neither applied nor forwarded again
+func (kg *KoinosGossip) validateTransaction(ctx context.Context, pid peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
 	err := kg.applyTransaction(ctx, pid, msg)
+	if errors.Is(err, p2perrors.ErrDuplicateTransaction) {
+		log.Debugf("Gossiped transaction from peer %v already known", msg.ReceivedFrom)
+		return pubsub.ValidationIgnore
+	}
 	if err != nil {
 		log.Warnf("Gossiped transaction not applied from peer %v: %s", msg.ReceivedFrom, err)
 		go func() {
@@ -322,9 +515,9 @@ func (kg *KoinosGossip) validateTransaction(ctx context.Context, pid peer.ID, ms
 			case <-ctx.Done():
 			}
 		}()
-		return false
+		return pubsub.ValidationReject
 	}
-	return true
+	return pubsub.ValidationAccept
 }
 
 func (kg *KoinosGossip) applyTransaction(ctx context.Context, pid peer.ID, msg *pubsub.Message) error {
@@ -344,10 +537,138 @@ func (kg *KoinosGossip) applyTransaction(ctx context.Context, pid peer.ID, msg *
 		return fmt.Errorf("%w, gossiped transaction missing id", p2perrors.ErrDeserialization)
 	}
 
+	// The transaction may have already arrived in a batch
+	if kg.seenTransactions.Contains(transaction.Id) {
+		return fmt.Errorf("%w - %s", p2perrors.ErrDuplicateTransaction, util.TransactionString(transaction))
+	}
+
+	if !kg.submissions.rateLimiter.allow(msg.ReceivedFrom, 1, time.Now()) {
+		return fmt.Errorf("%w - %s", p2perrors.ErrTransactionRateLimit, util.TransactionString(transaction))
+	}
+
 	if _, err := kg.rpc.ApplyTransaction(ctx, transaction); err != nil {
+		if errors.Is(err, p2perrors.ErrDuplicateTransaction) {
+			kg.seenTransactions.Add(transaction.Id)
+			return err
+		}
 		return fmt.Errorf("%w - %s, %v", p2perrors.ErrTransactionApplication, util.TransactionString(transaction), err.Error())
 	}
+	kg.seenTransactions.Add(transaction.Id)
 
 	log.Infof("Gossiped transaction applied - %s from peer %v", util.TransactionString(transaction), msg.ReceivedFrom)
 	return nil
 }
+
+func (kg *KoinosGossip) startTransactionBatchGossip(ctx context.Context) {
+	go func() {
+		batchChan := make(chan []byte, transactionBuffer)
+		defer close(batchChan)
+		kg.TransactionBatch.RegisterValidator(kg.validateTransactionBatch)
+		kg.TransactionBatch.Start(ctx, batchChan)
+		log.Debug("Started transaction batch gossip listener")
+
+		for {
+			select {
+			case _, ok := <-batchChan:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// validateTransactionBatch ignores a batch without new transactions, which is not forwarded again
+func (kg *KoinosGossip) validateTransactionBatch(ctx context.Context, pid peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	err := kg.applyTransactionBatch(ctx, pid, msg)
+	if errors.Is(err, p2perrors.ErrDuplicateTransaction) {
+		log.Debugf("Gossiped transaction batch from peer %v already known", msg.ReceivedFrom)
+		return pubsub.ValidationIgnore
+	}
+	if err != nil {
+		log.Warnf("Gossiped transaction batch not applied from peer %v: %s", msg.ReceivedFrom, err)
+		go func() {
+			select {
+			case kg.PeerErrorChan <- PeerError{msg.ReceivedFrom, err}:
+			case <-ctx.Done():
+			}
+		}()
+		return pubsub.ValidationReject
+	}
+	return pubsub.ValidationAccept
+}
+
+// applyTransactionBatch applies the batch, returning a single error for the whole batch so that a bad
+// batch is reported once. Transactions that are already known are not errors, but a batch holding only
+// known transactions returns ErrDuplicateTransaction.
+func (kg *KoinosGossip) applyTransactionBatch(ctx context.Context, pid peer.ID, msg *pubsub.Message) error {
+	log.Debug("Received transaction batch via gossip")
+	batch := &messages.TransactionBatch{}
+	err := proto.Unmarshal(msg.Data, batch)
+	if err != nil {
+		return fmt.Errorf("%w, %v", p2perrors.ErrDeserialization, err.Error())
+	}
+
+	// If the gossip message is from this node, consider it valid but do not apply it (since it has already been applied)
+	if msg.GetFrom() == kg.myPeerID {
+		return nil
+	}
+
+	if len(batch.Transactions) == 0 {
+		return fmt.Errorf("%w, gossiped transaction batch is empty", p2perrors.ErrDeserialization)
+	}
+
+	// The whole batch is rejected, since a batch is forwarded or dropped as a single message
+	if !kg.submissions.rateLimiter.allow(msg.ReceivedFrom, len(batch.Transactions), time.Now()) {
+		return fmt.Errorf("%w, batch of %v transactions", p2perrors.ErrTransactionRateLimit, len(batch.Transactions))
+	}
+
+	transactions := make([]*protocol.Transaction, 0, len(batch.Transactions))
+	for _, data := range batch.Transactions {
+		transaction := &protocol.Transaction{}
+		if err = proto.Unmarshal(data, transaction); err != nil {
+			return fmt.Errorf("%w, %v", p2perrors.ErrDeserialization, err.Error())
+		}
+
+		if transaction.Id == nil {
+			return fmt.Errorf("%w, gossiped transaction missing id", p2perrors.ErrDeserialization)
+		}
+
+		// Transactions that already arrived on either topic are not applied again
+		if !kg.seenTransactions.Contains(transaction.Id) {
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	// Any invalid transaction rejects the batch, so that invalid transactions are not forwarded to the network
+	var firstErr error
+	failed := 0
+	applied := 0
+	for i, err := range kg.rpc.ApplyTransactions(ctx, transactions) {
+		if err == nil || errors.Is(err, p2perrors.ErrDuplicateTransaction) {
+			kg.seenTransactions.Add(transactions[i].Id)
+			if err == nil {
+				applied++
+			}
+			continue
+		}
+
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s, %v", util.TransactionString(transactions[i]), err.Error())
+		}
+		failed++
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("%w - %v of %v transactions in batch, first %s", p2perrors.ErrTransactionApplication, failed, len(batch.Transactions), firstErr)
+	}
+
+	if applied == 0 {
+		return fmt.Errorf("%w, batch of %v known transactions", p2perrors.ErrDuplicateTransaction, len(batch.Transactions))
+	}
+
+	log.Infof("Gossiped transaction batch applied - %v of %v transactions from peer %v", applied, len(batch.Transactions), msg.ReceivedFrom)
+	return nil
+}
@@ -0,0 +1,563 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/messages"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/multiformats/go-multihash"
+	"google.golang.org/protobuf/proto"
+)
+
+type testGossipLocalRPC struct {
+	rpc.LocalRPC
+	known   map[string]bool
+	applied []*protocol.Block
+}
+
+func (t *testGossipLocalRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (*block_store.GetBlocksByIdResponse, error) {
+	resp := &block_store.GetBlocksByIdResponse{}
+	for _, id := range blockIDs {
+		item := &block_store.BlockItem{BlockId: id}
+		if t.known[string(id)] {
+			item.Block = &protocol.Block{Id: id}
+		}
+		resp.BlockItems = append(resp.BlockItems, item)
+	}
+	return resp, nil
+}
+
+func (t *testGossipLocalRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	if !t.known[string(block.Header.Previous)] {
+		return nil, errors.New("unknown previous block")
+	}
+	t.known[string(block.Id)] = true
+	t.applied = append(t.applied, block)
+	return &chain.SubmitBlockResponse{}, nil
+}
+
+type testGossipRemoteRPC struct {
+	rpc.RemoteRPC
+	blocks   map[string]*protocol.Block
+	requests int
+}
+
+func (t *testGossipRemoteRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) ([]*protocol.Block, error) {
+	t.requests++
+	blocks := make([]*protocol.Block, 0, len(blockIDs))
+	for _, id := range blockIDs {
+		if block, ok := t.blocks[string(id)]; ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+type testGossipRemoteRPCProvider struct {
+	remote *testGossipRemoteRPC
+}
+
+func (t *testGossipRemoteRPCProvider) GetRemoteRPC(id peer.ID) rpc.RemoteRPC {
+	return t.remote
+}
+
+type testGossipLIBProvider struct {
+	lib *koinos.BlockTopology
+}
+
+func (t *testGossipLIBProvider) GetLastIrreversibleBlock() *koinos.BlockTopology {
+	if t.lib == nil {
+		return &koinos.BlockTopology{}
+	}
+	return t.lib
+}
+
+// newTestGossipChain returns a chain of blocks where only the first is known locally and the rest are
+// known to the remote peer
+func newTestGossipChain(length int) ([]*protocol.Block, *testGossipLocalRPC, *testGossipRemoteRPC) {
+	local := &testGossipLocalRPC{known: make(map[string]bool)}
+	remote := &testGossipRemoteRPC{blocks: make(map[string]*protocol.Block)}
+	blocks := make([]*protocol.Block, 0, length)
+
+	previous, _ := multihash.Sum([]byte("genesis"), multihash.SHA2_256, -1)
+	local.known[string(previous)] = true
+
+	for i := 0; i < length; i++ {
+		id, _ := multihash.Sum([]byte{byte(i)}, multihash.SHA2_256, -1)
+		block := &protocol.Block{
+			Id: id,
+			Header: &protocol.BlockHeader{
+				Previous: previous,
+				Height:   uint64(i + 1),
+			},
+		}
+		blocks = append(blocks, block)
+		remote.blocks[string(id)] = block
+		previous = id
+	}
+
+	return blocks, local, remote
+}
+
+func newTestGossip(local *testGossipLocalRPC, remote *testGossipRemoteRPC, depth uint64) *KoinosGossip {
+	opts := options.NewGossipOptions()
+	opts.MissingBlockFetchDepth = depth
+
+	return &KoinosGossip{
+		rpc:               local,
+		myPeerID:          peer.ID("me"),
+		orphanPool:        NewOrphanBlockPool(*options.NewOrphanBlockPoolOptions()),
+		libProvider:       &testGossipLIBProvider{},
+		remoteRPCProvider: &testGossipRemoteRPCProvider{remote: remote},
+		submissions:       NewPeerSubmissionHandler(local, make(chan PeerError, 10), opts),
+		opts:              opts,
+	}
+}
+
+func TestApplyMissingBlocks(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(6)
+	kg := newTestGossip(local, remote, 5)
+
+	// The five ancestors of the last block fit in the fetch depth
+	err := kg.applyMissingBlocks(context.Background(), peer.ID("peer"), blocks[5])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if remote.requests != 5 {
+		t.Errorf("Expected 5 requests to the peer, was %v", remote.requests)
+	}
+
+	if len(local.applied) != 5 {
+		t.Fatalf("Expected 5 applied blocks, was %v", len(local.applied))
+	}
+
+	for i, block := range local.applied {
+		if !bytes.Equal(block.Id, blocks[i].Id) {
+			t.Errorf("Block %v applied out of order", i)
+		}
+	}
+}
+
+func TestApplyMissingBlocksDepth(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(7)
+	kg := newTestGossip(local, remote, 5)
+
+	// The six ancestors of the last block exceed the fetch depth
+	err := kg.applyMissingBlocks(context.Background(), peer.ID("peer"), blocks[6])
+	if !errors.Is(err, p2perrors.ErrUnknownPreviousBlock) {
+		t.Errorf("Expected ErrUnknownPreviousBlock, was %v", err)
+	}
+
+	if remote.requests != 5 {
+		t.Errorf("Expected 5 requests to the peer, was %v", remote.requests)
+	}
+
+	if len(local.applied) != 0 {
+		t.Errorf("Expected no applied blocks, was %v", len(local.applied))
+	}
+}
+
+func TestApplyMissingBlocksIrreversible(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(6)
+	kg := newTestGossip(local, remote, 5)
+	kg.libProvider = &testGossipLIBProvider{lib: &koinos.BlockTopology{Height: 3}}
+
+	// Ancestors below the last irreversible block are not fetched
+	err := kg.applyMissingBlocks(context.Background(), peer.ID("peer"), blocks[5])
+	if !errors.Is(err, p2perrors.ErrBlockIrreversibility) {
+		t.Errorf("Expected ErrBlockIrreversibility, was %v", err)
+	}
+
+	if len(local.applied) != 0 {
+		t.Errorf("Expected no applied blocks, was %v", len(local.applied))
+	}
+}
+
+func newTestGossipMessage(t *testing.T, block *protocol.Block) *pubsub.Message {
+	data, err := proto.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: peer.ID("peer")}
+}
+
+func TestApplyBlockOrphan(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(6)
+	kg := newTestGossip(local, remote, 5)
+
+	// The peer cannot provide an ancestor, so the block is held as an orphan
+	delete(remote.blocks, string(blocks[2].Id))
+
+	err := kg.applyBlock(context.Background(), peer.ID("peer"), newTestGossipMessage(t, blocks[5]))
+	if !errors.Is(err, p2perrors.ErrOrphanBlock) {
+		t.Errorf("Expected ErrOrphanBlock, was %v", err)
+	}
+
+	if kg.orphanPool.Len() != 1 {
+		t.Errorf("Expected 1 orphan block, was %v", kg.orphanPool.Len())
+	}
+}
+
+func TestApplyBlockInvalidAncestor(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(6)
+	kg := newTestGossip(local, remote, 5)
+
+	// The peer returns an ancestor without a header, which is the peer's fault
+	remote.blocks[string(blocks[2].Id)] = &protocol.Block{Id: blocks[2].Id}
+
+	err := kg.applyBlock(context.Background(), peer.ID("peer"), newTestGossipMessage(t, blocks[5]))
+	if !errors.Is(err, p2perrors.ErrDeserialization) {
+		t.Errorf("Expected ErrDeserialization, was %v", err)
+	}
+
+	if kg.orphanPool.Len() != 0 {
+		t.Errorf("Expected no orphan blocks, was %v", kg.orphanPool.Len())
+	}
+}
+
+// testBlockingRPC holds each block application until it is released
+type testBlockingRPC struct {
+	rpc.LocalRPC
+	release chan struct{}
+	err     error
+}
+
+func (t *testBlockingRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	<-t.release
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.LocalRPC.ApplyBlock(ctx, block)
+}
+
+func submitBlock(t *testing.T, kg *KoinosGossip, block *protocol.Block) {
+	data, err := proto.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kg.submissions.SubmitBlock(context.Background(), peer.ID("peer"), data)
+}
+
+// waitForSubmission waits until the block is being applied from a submission
+func waitForSubmission(t *testing.T, kg *KoinosGossip, block *protocol.Block) {
+	for i := 0; i < 100; i++ {
+		kg.submissions.blocks.mutex.Lock()
+		_, ok := kg.submissions.blocks.inFlight[string(block.Id)]
+		kg.submissions.blocks.mutex.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("Block submission did not start")
+}
+
+func TestApplyBlockSubmitted(t *testing.T) {
+	blocks, local, remote := newTestGossipChain(1)
+	kg := newTestGossip(local, remote, 5)
+
+	// A block submitted by a peer is not applied again when it arrives via gossip
+	submitBlock(t, kg, blocks[0])
+	submitBlock(t, kg, blocks[0])
+
+	if err := kg.applyBlock(context.Background(), peer.ID("peer"), newTestGossipMessage(t, blocks[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(local.applied) != 1 {
+		t.Errorf("Expected 1 applied block, was %v", len(local.applied))
+	}
+}
+
+func TestApplyBlockSubmissionInFlight(t *testing.T) {
+	for _, submitErr := range []error{nil, errors.New("timeout")} {
+		blocks, local, remote := newTestGossipChain(1)
+		kg := newTestGossip(local, remote, 5)
+		blocking := &testBlockingRPC{LocalRPC: local, release: make(chan struct{}), err: submitErr}
+		kg.submissions.localRPC = blocking
+
+		submitted := make(chan struct{})
+		go func() {
+			submitBlock(t, kg, blocks[0])
+			close(submitted)
+		}()
+		waitForSubmission(t, kg, blocks[0])
+
+		// The gossiped block waits for the submission of the same block
+		gossiped := make(chan error, 1)
+		go func() {
+			gossiped <- kg.applyBlock(context.Background(), peer.ID("peer"), newTestGossipMessage(t, blocks[0]))
+		}()
+
+		select {
+		case <-gossiped:
+			t.Fatal("Expected gossiped block to wait for the block submission")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		close(blocking.release)
+		<-submitted
+
+		if err := <-gossiped; err != nil {
+			t.Fatal(err)
+		}
+
+		// The gossiped block is applied only if the submission failed
+		if len(local.applied) != 1 {
+			t.Errorf("Expected 1 applied block after submission error %v, was %v", submitErr, len(local.applied))
+		}
+	}
+}
+
+type testGossipTransactionRPC struct {
+	rpc.LocalRPC
+	invalid map[string]bool
+	known   map[string]bool
+	applied int
+}
+
+func (t *testGossipTransactionRPC) ApplyTransaction(ctx context.Context, transaction *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+	if t.invalid[string(transaction.Id)] {
+		return nil, errors.New("invalid transaction")
+	}
+
+	if t.known[string(transaction.Id)] {
+		return nil, fmt.Errorf("%w, transaction already in mempool", p2perrors.ErrDuplicateTransaction)
+	}
+
+	t.applied++
+	return &chain.SubmitTransactionResponse{}, nil
+}
+
+func (t *testGossipTransactionRPC) ApplyTransactions(ctx context.Context, transactions []*protocol.Transaction) []error {
+	errs := make([]error, len(transactions))
+	for i, transaction := range transactions {
+		_, errs[i] = t.ApplyTransaction(ctx, transaction)
+	}
+
+	return errs
+}
+
+func newTestTransactionGossip(local rpc.LocalRPC, peerErrorChan chan PeerError, opts *options.GossipOptions) *KoinosGossip {
+	return &KoinosGossip{
+		rpc:              local,
+		myPeerID:         peer.ID("me"),
+		submissions:      NewPeerSubmissionHandler(local, peerErrorChan, opts),
+		seenTransactions: NewRecentIDs(seenTransactionCacheSize),
+		PeerErrorChan:    peerErrorChan,
+		opts:             opts,
+	}
+}
+
+func newTestTransaction(t *testing.T, name string) (*protocol.Transaction, []byte) {
+	id, err := multihash.Sum([]byte(name), multihash.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := &protocol.Transaction{Id: id}
+	data, err := proto.Marshal(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return transaction, data
+}
+
+func TestTransactionRateLimitScoring(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	peerErrorChan := make(chan PeerError)
+	handlerOpts := options.NewPeerErrorHandlerOptions()
+	handlerOpts.ErrorScores[options.TransactionRateLimitError] = options.ErrorScoreOptions{Score: 10, Halflife: time.Hour}
+	errorHandler := NewPeerErrorHandler(make(chan peer.ID), peerErrorChan, make(chan PeerReward), nil, *handlerOpts)
+	errorHandler.Start(ctx)
+
+	opts := options.NewGossipOptions()
+	opts.TransactionRateLimit = 1
+	opts.TransactionRateBurst = 1
+	kg := newTestTransactionGossip(&testGossipTransactionRPC{}, peerErrorChan, opts)
+
+	_, data := newTestTransaction(t, "transaction1")
+	msg := &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: peer.ID("peer")}
+
+	if kg.validateTransaction(ctx, peer.ID("peer"), msg) != pubsub.ValidationAccept {
+		t.Fatal("Expected the first transaction to be accepted")
+	}
+
+	// The transaction over the burst is rejected and scored as a rate limit error
+	_, data = newTestTransaction(t, "transaction2")
+	msg = &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: peer.ID("peer")}
+
+	if kg.validateTransaction(ctx, peer.ID("peer"), msg) != pubsub.ValidationReject {
+		t.Fatal("Expected the transaction over the rate limit to be rejected")
+	}
+
+	var history []PeerErrorRecord
+	for i := 0; i < 100 && len(history) == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		history = errorHandler.GetErrorHistory(ctx, peer.ID("peer"))
+	}
+
+	if len(history) != 1 || history[0].Category != options.TransactionRateLimitError || history[0].ScoreDelta != 10 {
+		t.Errorf("Expected 1 transaction rate limit error scored 10, was %v", history)
+	}
+}
+
+func TestApplyTransactionBatchRejected(t *testing.T) {
+	peerErrorChan := make(chan PeerError, 10)
+	local := &testGossipTransactionRPC{invalid: make(map[string]bool)}
+	kg := newTestTransactionGossip(local, peerErrorChan, options.NewGossipOptions())
+
+	batch := &messages.TransactionBatch{}
+	for i, name := range []string{"trx1", "trx2", "trx3"} {
+		transaction, data := newTestTransaction(t, name)
+		if i != 1 {
+			local.invalid[string(transaction.Id)] = true
+		}
+		batch.Transactions = append(batch.Transactions, data)
+	}
+
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch with any invalid transaction is not forwarded, and the batch is reported once
+	msg := &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: peer.ID("peer")}
+	if kg.validateTransactionBatch(context.Background(), peer.ID("peer"), msg) != pubsub.ValidationReject {
+		t.Fatal("Expected the batch with invalid transactions to be rejected")
+	}
+
+	select {
+	case peerErr := <-peerErrorChan:
+		if peerErr.id != peer.ID("peer") || !errors.Is(peerErr.err, p2perrors.ErrTransactionApplication) {
+			t.Errorf("Incorrect peer error reported. Was %v", peerErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a reported error")
+	}
+
+	select {
+	case peerErr := <-peerErrorChan:
+		t.Errorf("Unexpected peer error reported: %v", peerErr)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	if local.applied != 1 {
+		t.Errorf("Expected 1 applied transaction, was %v", local.applied)
+	}
+}
+
+func TestApplyTransactionBatchKnown(t *testing.T) {
+	peerErrorChan := make(chan PeerError, 10)
+	local := &testGossipTransactionRPC{known: make(map[string]bool)}
+	kg := newTestTransactionGossip(local, peerErrorChan, options.NewGossipOptions())
+
+	batch := &messages.TransactionBatch{}
+	for i, name := range []string{"trx1", "trx2", "trx3"} {
+		transaction, data := newTestTransaction(t, name)
+		if i != 1 {
+			local.known[string(transaction.Id)] = true
+		}
+		batch.Transactions = append(batch.Transactions, data)
+	}
+
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Transactions that are already known do not reject the batch
+	msg := &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: peer.ID("peer")}
+	if kg.validateTransactionBatch(context.Background(), peer.ID("peer"), msg) != pubsub.ValidationAccept {
+		t.Fatal("Expected the batch with known transactions to be accepted")
+	}
+
+	select {
+	case peerErr := <-peerErrorChan:
+		t.Errorf("Unexpected peer error reported: %v", peerErr)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	if local.applied != 1 {
+		t.Errorf("Expected 1 applied transaction, was %v", local.applied)
+	}
+}
+
+func TestTransactionTopicDedup(t *testing.T) {
+	ctx := context.Background()
+	peerErrorChan := make(chan PeerError, 10)
+	local := &testGossipTransactionRPC{}
+	kg := newTestTransactionGossip(local, peerErrorChan, options.NewGossipOptions())
+
+	_, data1 := newTestTransaction(t, "trx1")
+	_, data2 := newTestTransaction(t, "trx2")
+
+	batch, err := proto.Marshal(&messages.TransactionBatch{Transactions: [][]byte{data1, data2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction received on its own is not applied again in a batch
+	msg := &pubsub.Message{Message: &pb.Message{Data: data1}, ReceivedFrom: peer.ID("peer1")}
+	if kg.validateTransaction(ctx, peer.ID("peer1"), msg) != pubsub.ValidationAccept {
+		t.Fatal("Expected the transaction to be accepted")
+	}
+
+	msg = &pubsub.Message{Message: &pb.Message{Data: batch}, ReceivedFrom: peer.ID("peer2")}
+	if kg.validateTransactionBatch(ctx, peer.ID("peer2"), msg) != pubsub.ValidationAccept {
+		t.Fatal("Expected the batch with a new transaction to be accepted")
+	}
+
+	if local.applied != 2 {
+		t.Fatalf("Expected 2 applied transactions, was %v", local.applied)
+	}
+
+	// Transactions received in a batch are ignored on either topic, so they are not forwarded again
+	msg = &pubsub.Message{Message: &pb.Message{Data: data2}, ReceivedFrom: peer.ID("peer3")}
+	if kg.validateTransaction(ctx, peer.ID("peer3"), msg) != pubsub.ValidationIgnore {
+		t.Error("Expected the transaction from the batch to be ignored")
+	}
+
+	msg = &pubsub.Message{Message: &pb.Message{Data: batch}, ReceivedFrom: peer.ID("peer3")}
+	if kg.validateTransactionBatch(ctx, peer.ID("peer3"), msg) != pubsub.ValidationIgnore {
+		t.Error("Expected the batch of known transactions to be ignored")
+	}
+
+	if local.applied != 2 {
+		t.Errorf("Expected 2 applied transactions, was %v", local.applied)
+	}
+
+	// A transaction the chain already knows is ignored rather than reported
+	transaction3, data3 := newTestTransaction(t, "trx3")
+	local.known = map[string]bool{string(transaction3.Id): true}
+
+	msg = &pubsub.Message{Message: &pb.Message{Data: data3}, ReceivedFrom: peer.ID("peer3")}
+	if kg.validateTransaction(ctx, peer.ID("peer3"), msg) != pubsub.ValidationIgnore {
+		t.Error("Expected the transaction known to the chain to be ignored")
+	}
+
+	select {
+	case peerErr := <-peerErrorChan:
+		t.Errorf("Unexpected peer error reported: %v", peerErr)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+type peerRTT struct {
+	id  peer.ID
+	rtt time.Duration
+}
+
+// smoothRTT adds a round trip time sample to an exponentially weighted moving average.
+// The first sample is taken as is.
+func smoothRTT(average time.Duration, sample time.Duration, smoothingFactor float64) time.Duration {
+	if average == 0 {
+		return sample
+	}
+
+	return time.Duration(smoothingFactor*float64(sample) + (1-smoothingFactor)*float64(average))
+}
+
+// pingLoop pings the peer with the libp2p ping protocol until the context is done
+func (c *ConnectionManager) pingLoop(ctx context.Context, pid peer.ID) {
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, c.peerOpts.PingTimeout)
+		result, ok := <-ping.Ping(pingCtx, c.host, pid)
+		cancel()
+
+		if ok && result.Error == nil {
+			select {
+			case c.peerRTTChan <- peerRTT{id: pid, rtt: result.RTT}:
+			case <-ctx.Done():
+				return
+			}
+		} else if ctx.Err() == nil {
+			log.Debugf("Error pinging peer %v: %v", pid, result.Error)
+		}
+
+		select {
+		case <-time.After(c.peerOpts.PingInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *ConnectionManager) handlePeerRTT(sample peerRTT) {
+	if peerConn, ok := c.connectedPeers[sample.id]; ok {
+		peerConn.rtt = smoothRTT(peerConn.rtt, sample.rtt, c.peerOpts.RTTSmoothingFactor)
+		if peerConn.peer != nil {
+			peerConn.peer.setRTT(peerConn.rtt)
+		}
+	}
+}
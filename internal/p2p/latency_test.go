@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestSmoothRTT(t *testing.T) {
+	rtt := smoothRTT(0, time.Millisecond*100, 0.2)
+	if rtt != time.Millisecond*100 {
+		t.Errorf("Expected first sample to be taken as is, was %v", rtt)
+	}
+
+	rtt = smoothRTT(rtt, time.Millisecond*200, 0.2)
+	if rtt != time.Millisecond*120 {
+		t.Errorf("Incorrect smoothed RTT. Expected %v, was %v", time.Millisecond*120, rtt)
+	}
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	opts := options.NewPeerConnectionOptions()
+	opts.RTTTimeoutMultiplier = 4
+
+	p := &PeerConnection{opts: opts}
+	if p.remoteTimeout(time.Second) != time.Second {
+		t.Errorf("Expected unchanged timeout before the RTT is known, was %v", p.remoteTimeout(time.Second))
+	}
+
+	p.setRTT(time.Millisecond * 250)
+	if p.remoteTimeout(time.Second) != time.Second*2 {
+		t.Errorf("Incorrect timeout. Expected %v, was %v", time.Second*2, p.remoteTimeout(time.Second))
+	}
+}
+
+func TestSyncedPeersByRTT(t *testing.T) {
+	c := &ConnectionManager{
+		connectedPeers: map[peer.ID]*peerConnectionContext{
+			"slow":     {synced: true, rtt: time.Millisecond * 300},
+			"unknown":  {synced: true},
+			"fast":     {synced: true, rtt: time.Millisecond * 10},
+			"unsynced": {synced: false, rtt: time.Millisecond},
+			"medium":   {synced: true, rtt: time.Millisecond * 50},
+		},
+	}
+
+	peers := c.handleSyncedPeers()
+	expected := []peer.ID{"fast", "medium", "slow", "unknown"}
+	if len(peers) != len(expected) {
+		t.Fatalf("Incorrect number of synced peers. Expected %v, was %v", len(expected), len(peers))
+	}
+
+	for i := range expected {
+		if peers[i] != expected[i] {
+			t.Errorf("Incorrect synced peer order. Expected %v, was %v", expected, peers)
+			break
+		}
+	}
+}
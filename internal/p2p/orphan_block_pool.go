@@ -0,0 +1,187 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	util "github.com/koinos/koinos-util-golang"
+	"github.com/multiformats/go-multihash"
+	"google.golang.org/protobuf/proto"
+)
+
+const orphanExpirationCheckTime = time.Second * 10
+
+type orphanBlock struct {
+	block      *protocol.Block
+	size       uint64
+	expiration time.Time
+}
+
+// OrphanBlockPool holds blocks whose parent is not yet known until the parent is applied
+type OrphanBlockPool struct {
+	blocksByID       map[string]*orphanBlock
+	blocksByPrevious map[string][]*orphanBlock
+	totalBytes       uint64
+	mutex            sync.Mutex
+
+	opts options.OrphanBlockPoolOptions
+}
+
+// Add a block to the orphan pool. Returns false if the block could not be held.
+func (o *OrphanBlockPool) Add(block *protocol.Block) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if _, ok := o.blocksByID[string(block.Id)]; ok {
+		return true
+	}
+
+	size := uint64(proto.Size(block))
+	if size > o.opts.MaxBytes || o.opts.MaxCount == 0 {
+		return false
+	}
+
+	o.removeExpired(time.Now())
+
+	// Make room by evicting the blocks closest to expiration
+	for uint64(len(o.blocksByID)) >= o.opts.MaxCount || o.totalBytes+size > o.opts.MaxBytes {
+		o.remove(o.oldest())
+	}
+
+	orphan := &orphanBlock{
+		block:      block,
+		size:       size,
+		expiration: time.Now().Add(o.opts.Expiration),
+	}
+
+	o.blocksByID[string(block.Id)] = orphan
+	o.blocksByPrevious[string(block.Header.Previous)] = append(o.blocksByPrevious[string(block.Header.Previous)], orphan)
+	o.totalBytes += size
+
+	return true
+}
+
+// PopChildren removes and returns all held blocks whose parent is the given block
+func (o *OrphanBlockPool) PopChildren(parentID multihash.Multihash) []*protocol.Block {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	orphans, ok := o.blocksByPrevious[string(parentID)]
+	if !ok {
+		return nil
+	}
+
+	delete(o.blocksByPrevious, string(parentID))
+
+	now := time.Now()
+	blocks := make([]*protocol.Block, 0, len(orphans))
+	for _, orphan := range orphans {
+		delete(o.blocksByID, string(orphan.block.Id))
+		o.totalBytes -= orphan.size
+		if orphan.expiration.After(now) {
+			blocks = append(blocks, orphan.block)
+		}
+	}
+
+	return blocks
+}
+
+// Len returns the number of blocks held in the pool
+func (o *OrphanBlockPool) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return len(o.blocksByID)
+}
+
+// ApplyChildren applies all held descendants of the given block
+func (o *OrphanBlockPool) ApplyChildren(ctx context.Context, localRPC rpc.LocalRPC, parentID multihash.Multihash) error {
+	children := o.PopChildren(parentID)
+
+	for len(children) > 0 {
+		block := children[0]
+		children = children[1:]
+
+		if _, err := localRPC.ApplyBlock(ctx, block); err != nil {
+			return blockApplicationError(block, err)
+		}
+
+		log.Infof("Orphan block applied - %s", util.BlockString(block))
+		children = append(children, o.PopChildren(block.Id)...)
+	}
+
+	return nil
+}
+
+func (o *OrphanBlockPool) oldest() *orphanBlock {
+	var oldest *orphanBlock
+	for _, orphan := range o.blocksByID {
+		if oldest == nil || orphan.expiration.Before(oldest.expiration) {
+			oldest = orphan
+		}
+	}
+
+	return oldest
+}
+
+func (o *OrphanBlockPool) remove(orphan *orphanBlock) {
+	if orphan == nil {
+		return
+	}
+
+	delete(o.blocksByID, string(orphan.block.Id))
+	o.totalBytes -= orphan.size
+
+	previous := string(orphan.block.Header.Previous)
+	siblings := o.blocksByPrevious[previous]
+	for i, sibling := range siblings {
+		if sibling == orphan {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+
+	if len(siblings) == 0 {
+		delete(o.blocksByPrevious, previous)
+	} else {
+		o.blocksByPrevious[previous] = siblings
+	}
+}
+
+func (o *OrphanBlockPool) removeExpired(now time.Time) {
+	for _, orphan := range o.blocksByID {
+		if !orphan.expiration.After(now) {
+			o.remove(orphan)
+		}
+	}
+}
+
+// Start periodically removing expired blocks
+func (o *OrphanBlockPool) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-time.After(orphanExpirationCheckTime):
+				o.mutex.Lock()
+				o.removeExpired(time.Now())
+				o.mutex.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// NewOrphanBlockPool creates a new OrphanBlockPool
+func NewOrphanBlockPool(opts options.OrphanBlockPoolOptions) *OrphanBlockPool {
+	return &OrphanBlockPool{
+		blocksByID:       make(map[string]*orphanBlock),
+		blocksByPrevious: make(map[string][]*orphanBlock),
+		opts:             opts,
+	}
+}
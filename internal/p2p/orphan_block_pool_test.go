@@ -0,0 +1,108 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/multiformats/go-multihash"
+)
+
+func createOrphanBlock(height uint64, previous uint64) *protocol.Block {
+	id, _ := multihash.Encode(make([]byte, 0), height)
+	previousID, _ := multihash.Encode(make([]byte, 0), previous)
+
+	return &protocol.Block{
+		Id: id,
+		Header: &protocol.BlockHeader{
+			Height:   height,
+			Previous: previousID,
+		},
+	}
+}
+
+func TestOrphanBlockPool(t *testing.T) {
+	opts := options.NewOrphanBlockPoolOptions()
+	opts.MaxCount = 3
+	opts.Expiration = time.Millisecond * 100
+
+	pool := NewOrphanBlockPool(*opts)
+
+	// Two competing children of block 1 and a grandchild
+	pool.Add(createOrphanBlock(2, 1))
+	pool.Add(createOrphanBlock(3, 2))
+	pool.Add(createOrphanBlock(4, 1))
+
+	if pool.Len() != 3 {
+		t.Errorf("Incorrect number of orphan blocks. Expected 3, was %v", pool.Len())
+	}
+
+	// Adding the same block twice should not evict anything
+	pool.Add(createOrphanBlock(2, 1))
+	if pool.Len() != 3 {
+		t.Errorf("Duplicate orphan block was added to the pool")
+	}
+
+	parentID, _ := multihash.Encode(make([]byte, 0), 1)
+	children := pool.PopChildren(parentID)
+	if len(children) != 2 {
+		t.Errorf("Incorrect number of children returned. Expected 2, was %v", len(children))
+	}
+
+	if pool.Len() != 1 {
+		t.Errorf("Incorrect number of orphan blocks. Expected 1, was %v", pool.Len())
+	}
+
+	if len(pool.PopChildren(parentID)) != 0 {
+		t.Errorf("Children were returned twice")
+	}
+
+	// Exceeding the count limit evicts the oldest block
+	pool.Add(createOrphanBlock(5, 4))
+	pool.Add(createOrphanBlock(6, 5))
+	pool.Add(createOrphanBlock(7, 6))
+
+	if pool.Len() != 3 {
+		t.Errorf("Incorrect number of orphan blocks. Expected 3, was %v", pool.Len())
+	}
+
+	grandparentID, _ := multihash.Encode(make([]byte, 0), 2)
+	if len(pool.PopChildren(grandparentID)) != 0 {
+		t.Errorf("Oldest orphan block was not evicted")
+	}
+
+	// Expired blocks are not returned
+	time.Sleep(time.Millisecond * 150)
+
+	blockID, _ := multihash.Encode(make([]byte, 0), 4)
+	if len(pool.PopChildren(blockID)) != 0 {
+		t.Errorf("Expired orphan block was returned")
+	}
+
+	if pool.Len() != 2 {
+		t.Errorf("Incorrect number of orphan blocks. Expected 2, was %v", pool.Len())
+	}
+
+	// All siblings are returned together
+	pool = NewOrphanBlockPool(*opts)
+	pool.Add(createOrphanBlock(2, 1))
+	pool.Add(createOrphanBlock(4, 1))
+	pool.Add(createOrphanBlock(8, 1))
+
+	children = pool.PopChildren(parentID)
+	if len(children) != 3 {
+		t.Errorf("Incorrect number of children returned. Expected 3, was %v", len(children))
+	}
+
+	if pool.Len() != 0 {
+		t.Errorf("Incorrect number of orphan blocks. Expected 0, was %v", pool.Len())
+	}
+
+	// A block larger than the pool can never be held
+	opts.MaxBytes = 1
+	pool = NewOrphanBlockPool(*opts)
+	if pool.Add(createOrphanBlock(2, 1)) {
+		t.Errorf("Block larger than the pool was added")
+	}
+}
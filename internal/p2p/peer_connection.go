@@ -3,9 +3,11 @@ package p2p
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"errors"
+	"sync/atomic"
 	"time"
 
+	log "github.com/koinos/koinos-log-golang"
 	"github.com/koinos/koinos-p2p/internal/options"
 	"github.com/koinos/koinos-p2p/internal/p2perrors"
 	"github.com/koinos/koinos-p2p/internal/rpc"
@@ -19,19 +21,72 @@ type PeerConnection struct {
 	id         peer.ID
 	isSynced   bool
 	gossipVote bool
+	rtt        int64 // Round trip time in nanoseconds, accessed atomically
+	blockSync  int32 // Non-zero if blocks may be requested from the peer, accessed atomically
+	waiting    bool  // The peer has blocks to sync, but is not a block sync peer
+	batch      *blockRequestBatch
 	opts       *options.PeerConnectionOptions
 
 	requestBlockChan chan signalRequestBlocks
 
 	libProvider    LastIrreversibleBlockProvider
+	orphanPool     *OrphanBlockPool
 	localRPC       rpc.LocalRPC
 	peerRPC        rpc.RemoteRPC
 	peerErrorChan  chan<- PeerError
+	peerRewardChan chan<- PeerReward
 	gossipVoteChan chan<- GossipVote
+	peerHeadChan   chan<- peerHead
+	syncedChan     chan<- SyncedBlocks
 }
 
-func (p *PeerConnection) requestBlocks() {
-	p.requestBlockChan <- signalRequestBlocks{}
+func (p *PeerConnection) requestBlocks(ctx context.Context) {
+	select {
+	case p.requestBlockChan <- signalRequestBlocks{}:
+	case <-ctx.Done():
+	}
+}
+
+// setRTT sets the round trip time used to extend remote rpc timeouts
+func (p *PeerConnection) setRTT(rtt time.Duration) {
+	atomic.StoreInt64(&p.rtt, int64(rtt))
+}
+
+// setBlockSync sets if blocks may be requested from the peer
+func (p *PeerConnection) setBlockSync(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&p.blockSync, value)
+}
+
+func (p *PeerConnection) isBlockSyncPeer() bool {
+	return atomic.LoadInt32(&p.blockSync) != 0
+}
+
+// remoteTimeout extends a remote rpc timeout by the round trip time to the peer
+func (p *PeerConnection) remoteTimeout(timeout time.Duration) time.Duration {
+	rtt := time.Duration(atomic.LoadInt64(&p.rtt))
+	return timeout + time.Duration(p.opts.RTTTimeoutMultiplier)*rtt
+}
+
+func (p *PeerConnection) reportReward(ctx context.Context, reward Reward, count uint64) {
+	go func() {
+		select {
+		case p.peerRewardChan <- PeerReward{id: p.id, reward: reward, count: count}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (p *PeerConnection) reportSyncedBlocks(ctx context.Context, count uint64, headHeight uint64) {
+	go func() {
+		select {
+		case p.syncedChan <- SyncedBlocks{id: p.id, count: count, headHeight: headHeight}:
+		case <-ctx.Done():
+		}
+	}()
 }
 
 func (p *PeerConnection) handshake(ctx context.Context) error {
@@ -44,7 +99,7 @@ func (p *PeerConnection) handshake(ctx context.Context) error {
 	}
 
 	// Get peer's chain id
-	rpcContext, cancelPeerGetChainID := context.WithTimeout(ctx, p.opts.RemoteRPCTimeout)
+	rpcContext, cancelPeerGetChainID := context.WithTimeout(ctx, p.remoteTimeout(p.opts.RemoteRPCTimeout))
 	defer cancelPeerGetChainID()
 	peerChainID, err := p.peerRPC.GetChainID(rpcContext)
 	if err != nil {
@@ -56,7 +111,7 @@ func (p *PeerConnection) handshake(ctx context.Context) error {
 	}
 
 	// Get peer's head block
-	rpcContext, cancelGetPeerHead := context.WithTimeout(ctx, p.opts.RemoteRPCTimeout)
+	rpcContext, cancelGetPeerHead := context.WithTimeout(ctx, p.remoteTimeout(p.opts.RemoteRPCTimeout))
 	defer cancelGetPeerHead()
 	peerHeadID, _, err := p.peerRPC.GetHeadBlock(rpcContext)
 	if err != nil {
@@ -64,7 +119,7 @@ func (p *PeerConnection) handshake(ctx context.Context) error {
 	}
 
 	for _, checkpoint := range p.opts.Checkpoints {
-		rpcContext, cancel := context.WithTimeout(ctx, p.opts.RemoteRPCTimeout)
+		rpcContext, cancel := context.WithTimeout(ctx, p.remoteTimeout(p.opts.RemoteRPCTimeout))
 		defer cancel()
 		peerBlock, err := p.peerRPC.GetAncestorBlockID(rpcContext, peerHeadID, checkpoint.BlockHeight)
 		if err != nil {
@@ -80,27 +135,42 @@ func (p *PeerConnection) handshake(ctx context.Context) error {
 }
 
 func (p *PeerConnection) handleRequestBlocks(ctx context.Context) error {
+	p.waiting = false
+
 	// Get my last irreversible block
 	lib := p.libProvider.GetLastIrreversibleBlock()
 
 	// Get peer's head block
-	rpcContext, cancelGetPeerHead := context.WithTimeout(ctx, p.opts.RemoteRPCTimeout)
+	rpcContext, cancelGetPeerHead := context.WithTimeout(ctx, p.remoteTimeout(p.opts.RemoteRPCTimeout))
 	defer cancelGetPeerHead()
+	requestStart := time.Now()
 	peerHeadID, peerHeadHeight, err := p.peerRPC.GetHeadBlock(rpcContext)
 	if err != nil {
 		return err
 	}
 
+	if time.Since(requestStart) < p.opts.LowLatencyThreshold {
+		p.reportReward(ctx, LowLatencyReward, 1)
+	}
+
+	go func() {
+		select {
+		case p.peerHeadChan <- peerHead{id: p.id, height: peerHeadHeight}:
+		case <-ctx.Done():
+		}
+	}()
+
 	// If the peer is in the past, it is not an error, but we don't need anything from them
 	if peerHeadHeight <= lib.Height {
 		p.isSynced = true
+		p.reportSyncedBlocks(ctx, 0, peerHeadHeight)
 		return nil
 	}
 
 	// If LIB is 0, we are still at genesis and could connec to any chain
 	if lib.Height > 0 {
 		// Check if my LIB connect's to peer's head block
-		rpcContext, cancelGetAncestorBlock := context.WithTimeout(ctx, p.opts.RemoteRPCTimeout)
+		rpcContext, cancelGetAncestorBlock := context.WithTimeout(ctx, p.remoteTimeout(p.opts.RemoteRPCTimeout))
 		defer cancelGetAncestorBlock()
 		ancestorBlock, err := p.peerRPC.GetAncestorBlockID(rpcContext, peerHeadID, lib.Height)
 		if err != nil {
@@ -112,28 +182,64 @@ func (p *PeerConnection) handleRequestBlocks(ctx context.Context) error {
 		}
 	}
 
+	// Every peer votes on gossip, so whether the peer is synced is first decided from the local head
+	rpcContext, cancelGetLocalHead := context.WithTimeout(ctx, p.opts.LocalRPCTimeout)
+	defer cancelGetLocalHead()
+	headInfo, err := p.localRPC.GetHeadBlock(rpcContext)
+	if err != nil {
+		return err
+	}
+	p.isSynced = peerHeadHeight < headInfo.GetHeadTopology().GetHeight()+p.opts.SyncedBlockDelta
+
+	// Only block sync peers are asked for blocks, the others wait until they are selected
+	if !p.isBlockSyncPeer() {
+		p.waiting = !p.isSynced
+		return nil
+	}
+
 	blocksToRequest := peerHeadHeight - lib.Height
-	if blocksToRequest > p.opts.BlockRequestBatchSize {
-		blocksToRequest = p.opts.BlockRequestBatchSize
+	if blocksToRequest > p.batch.size {
+		blocksToRequest = p.batch.size
 	}
 
 	// Request blocks
-	rpcContext, cancelGetBlocks := context.WithTimeout(ctx, p.opts.BlockRequestTimeout)
+	rpcContext, cancelGetBlocks := context.WithTimeout(ctx, p.remoteTimeout(p.batch.timeout(blocksToRequest)))
 	defer cancelGetBlocks()
+	requestStart = time.Now()
 	blocks, err := p.peerRPC.GetBlocks(rpcContext, peerHeadID, lib.Height+1, uint32(blocksToRequest))
 	if err != nil {
+		if errors.Is(err, p2perrors.ErrPeerRPCTimeout) {
+			p.batch.timedOut()
+		}
 		return err
 	}
+	p.batch.succeeded(uint64(len(blocks)), time.Since(requestStart))
 
 	// Apply blocks to local node
-	for _, block := range blocks {
+	for i := range blocks {
+		block := &blocks[i]
 		rpcContext, cancelApplyBlock := context.WithTimeout(ctx, time.Second)
 		defer cancelApplyBlock()
-		_, err = p.localRPC.ApplyBlock(rpcContext, &block)
+		_, err = p.localRPC.ApplyBlock(rpcContext, block)
 		if err != nil {
-			return fmt.Errorf("%w: %s", p2perrors.ErrBlockApplication, err.Error())
+			if i > 0 {
+				p.reportReward(ctx, SyncBlockReward, uint64(i))
+			}
+			p.reportSyncedBlocks(ctx, uint64(i), peerHeadHeight)
+			return blockApplicationError(block, err)
 		}
+
+		// Orphans are not attributed to this peer, so a failure only needs to be logged
+		err = p.orphanPool.ApplyChildren(ctx, p.localRPC, block.Id)
+		if err != nil {
+			log.Warnf("Orphan block not applied: %s", err)
+		}
+	}
+
+	if len(blocks) > 0 {
+		p.reportReward(ctx, SyncBlockReward, uint64(len(blocks)))
 	}
+	p.reportSyncedBlocks(ctx, uint64(len(blocks)), peerHeadHeight)
 
 	// We will consider ourselves as syncing if we have more than 5 blocks to sync
 	p.isSynced = peerHeadHeight-blocks[len(blocks)-1].Header.Height < p.opts.SyncedBlockDelta
@@ -159,7 +265,7 @@ func (p *PeerConnection) connectionLoop(ctx context.Context) {
 		case <-p.requestBlockChan:
 			err := p.handleRequestBlocks(ctx)
 			if err != nil {
-				go time.AfterFunc(time.Second, p.requestBlocks)
+				time.AfterFunc(time.Second, func() { p.requestBlocks(ctx) })
 				go func() {
 					select {
 					case p.peerErrorChan <- PeerError{id: p.id, err: err}:
@@ -171,9 +277,11 @@ func (p *PeerConnection) connectionLoop(ctx context.Context) {
 					p.reportGossipVote(ctx)
 				}
 				if p.isSynced {
-					go time.AfterFunc(p.opts.SyncedPingTime, p.requestBlocks)
+					time.AfterFunc(p.opts.SyncedPingTime, func() { p.requestBlocks(ctx) })
+				} else if p.waiting {
+					time.AfterFunc(time.Second, func() { p.requestBlocks(ctx) })
 				} else {
-					go p.requestBlocks()
+					go p.requestBlocks(ctx)
 				}
 			}
 		}
@@ -197,7 +305,7 @@ func (p *PeerConnection) Start(ctx context.Context) {
 			} else {
 				p.reportGossipVote(ctx)
 				go p.connectionLoop(ctx)
-				go p.requestBlocks()
+				go p.requestBlocks(ctx)
 				return
 			}
 			select {
@@ -210,17 +318,22 @@ func (p *PeerConnection) Start(ctx context.Context) {
 }
 
 // NewPeerConnection creates a PeerConnection
-func NewPeerConnection(id peer.ID, libProvider LastIrreversibleBlockProvider, localRPC rpc.LocalRPC, peerRPC rpc.RemoteRPC, peerErrorChan chan<- PeerError, gossipVoteChan chan<- GossipVote, opts *options.PeerConnectionOptions) *PeerConnection {
+func NewPeerConnection(id peer.ID, libProvider LastIrreversibleBlockProvider, orphanPool *OrphanBlockPool, localRPC rpc.LocalRPC, peerRPC rpc.RemoteRPC, peerErrorChan chan<- PeerError, peerRewardChan chan<- PeerReward, gossipVoteChan chan<- GossipVote, peerHeadChan chan<- peerHead, syncedChan chan<- SyncedBlocks, opts *options.PeerConnectionOptions) *PeerConnection {
 	return &PeerConnection{
 		id:               id,
 		isSynced:         false,
 		gossipVote:       false,
+		batch:            newBlockRequestBatch(opts),
 		opts:             opts,
 		requestBlockChan: make(chan signalRequestBlocks),
 		libProvider:      libProvider,
+		orphanPool:       orphanPool,
 		localRPC:         localRPC,
 		peerRPC:          peerRPC,
 		peerErrorChan:    peerErrorChan,
+		peerRewardChan:   peerRewardChan,
 		gossipVoteChan:   gossipVoteChan,
+		peerHeadChan:     peerHeadChan,
+		syncedChan:       syncedChan,
 	}
 }
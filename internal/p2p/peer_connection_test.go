@@ -0,0 +1,94 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+type testSyncLocalRPC struct {
+	rpc.LocalRPC
+	head uint64
+}
+
+func (t *testSyncLocalRPC) GetChainID(ctx context.Context) (*chain.GetChainIdResponse, error) {
+	return &chain.GetChainIdResponse{ChainId: []byte("chain")}, nil
+}
+
+func (t *testSyncLocalRPC) GetHeadBlock(ctx context.Context) (*chain.GetHeadInfoResponse, error) {
+	return &chain.GetHeadInfoResponse{HeadTopology: &koinos.BlockTopology{Height: t.head}}, nil
+}
+
+func (t *testSyncLocalRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	return &chain.SubmitBlockResponse{}, nil
+}
+
+type testSyncRemoteRPC struct {
+	rpc.RemoteRPC
+	head uint64
+}
+
+func (t *testSyncRemoteRPC) GetChainID(ctx context.Context) (multihash.Multihash, error) {
+	return multihash.Multihash("chain"), nil
+}
+
+func (t *testSyncRemoteRPC) GetHeadBlock(ctx context.Context) (multihash.Multihash, uint64, error) {
+	return multihash.Multihash("head"), t.head, nil
+}
+
+func (t *testSyncRemoteRPC) GetBlocks(ctx context.Context, headBlockID multihash.Multihash, startBlockHeight uint64, batchSize uint32) ([]protocol.Block, error) {
+	blocks := make([]protocol.Block, 0, batchSize)
+	for height := startBlockHeight; height <= t.head && len(blocks) < int(batchSize); height++ {
+		id, err := multihash.Sum([]byte(fmt.Sprint(height)), multihash.SHA2_256, -1)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, protocol.Block{Id: id, Header: &protocol.BlockHeader{Height: height}})
+	}
+
+	return blocks, nil
+}
+
+func TestPeerConnectionSyncedVote(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := options.NewPeerConnectionOptions()
+	opts.BlockSyncPeers = 2
+	local := &testSyncLocalRPC{head: 10}
+	orphanPool := NewOrphanBlockPool(*options.NewOrphanBlockPoolOptions())
+	gossipVoteChan := make(chan GossipVote)
+
+	// Only some of the peers are block sync peers, but all of them are synced and vote for gossip
+	peerCount := int(opts.BlockSyncPeers) + 3
+	for i := 0; i < peerCount; i++ {
+		p := NewPeerConnection(peer.ID(fmt.Sprint(i)), &testGossipLIBProvider{}, orphanPool, local, &testSyncRemoteRPC{head: 10},
+			make(chan PeerError), make(chan PeerReward), gossipVoteChan, make(chan peerHead), make(chan SyncedBlocks), opts)
+		p.setBlockSync(uint64(i) < opts.BlockSyncPeers)
+		p.Start(ctx)
+	}
+
+	votes := make(map[peer.ID]bool)
+	synced := 0
+	timeout := time.After(time.Second * 5)
+	for synced < peerCount {
+		select {
+		case vote := <-gossipVoteChan:
+			if vote.synced && !votes[vote.peer] {
+				synced++
+			}
+			votes[vote.peer] = vote.synced
+		case <-timeout:
+			t.Fatalf("Expected %v peers to vote synced, was %v", peerCount, synced)
+		}
+	}
+}
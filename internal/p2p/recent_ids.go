@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"sync"
+)
+
+// RecentIDs is a bounded set of block or transaction IDs, forgetting the oldest ID once full
+type RecentIDs struct {
+	ids   map[string]int // The slot of each ID in order
+	order []string
+	next  int
+	mutex sync.Mutex
+}
+
+// NewRecentIDs creates a RecentIDs holding up to size IDs
+func NewRecentIDs(size int) *RecentIDs {
+	return &RecentIDs{
+		ids:   make(map[string]int, size),
+		order: make([]string, size),
+	}
+}
+
+// Add adds an ID, returning false if it was already present
+func (r *RecentIDs) Add(id []byte) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := string(id)
+	if _, ok := r.ids[key]; ok {
+		return false
+	}
+
+	// The slot's ID may have been removed, and even added again in another slot
+	if slot, ok := r.ids[r.order[r.next]]; ok && slot == r.next {
+		delete(r.ids, r.order[r.next])
+	}
+
+	r.order[r.next] = key
+	r.ids[key] = r.next
+	r.next = (r.next + 1) % len(r.order)
+
+	return true
+}
+
+// Contains returns if the ID is present
+func (r *RecentIDs) Contains(id []byte) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.ids[string(id)]
+	return ok
+}
+
+// Remove removes an ID, so that it is treated as new when added again
+func (r *RecentIDs) Remove(id []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.ids, string(id))
+}
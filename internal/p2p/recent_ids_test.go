@@ -0,0 +1,41 @@
+package p2p
+
+import (
+	"testing"
+)
+
+func TestRecentIDs(t *testing.T) {
+	r := NewRecentIDs(3)
+
+	if !r.Add([]byte("a")) || r.Add([]byte("a")) {
+		t.Errorf("Expected only the first add of an ID to succeed")
+	}
+
+	r.Add([]byte("b"))
+	r.Add([]byte("c"))
+	r.Add([]byte("d"))
+
+	if r.Contains([]byte("a")) {
+		t.Errorf("Expected the oldest ID to be forgotten")
+	}
+
+	if !r.Contains([]byte("b")) || !r.Contains([]byte("c")) || !r.Contains([]byte("d")) {
+		t.Errorf("Expected the newest IDs to be present")
+	}
+
+	// An ID added again after being removed is not forgotten when its old slot is reused
+	r.Remove([]byte("c"))
+	if r.Contains([]byte("c")) {
+		t.Errorf("Expected removed ID to be absent")
+	}
+
+	r.Add([]byte("c"))
+	r.Add([]byte("e"))
+	if !r.Contains([]byte("c")) {
+		t.Errorf("Expected ID added after removal to outlive its old slot")
+	}
+
+	if r.Contains([]byte("b")) || !r.Contains([]byte("d")) || !r.Contains([]byte("e")) {
+		t.Errorf("Incorrect IDs after reuse of the removed slot")
+	}
+}
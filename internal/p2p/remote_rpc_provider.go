@@ -0,0 +1,11 @@
+package p2p
+
+import (
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// RemoteRPCProvider is an interface for providing a RemoteRPC to a given peer
+type RemoteRPCProvider interface {
+	GetRemoteRPC(id peer.ID) rpc.RemoteRPC
+}
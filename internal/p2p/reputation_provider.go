@@ -0,0 +1,12 @@
+package p2p
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ReputationProvider is an interface for providing the reputation of peers
+type ReputationProvider interface {
+	GetReputations(ctx context.Context, ids []peer.ID) map[peer.ID]int64
+}
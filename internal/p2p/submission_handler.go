@@ -0,0 +1,195 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	util "github.com/koinos/koinos-util-golang"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// submittedBlockCacheSize is how many recently applied submitted block IDs are remembered
+const submittedBlockCacheSize = 1024
+
+// submittedBlocks tracks the submitted blocks being applied and those recently applied, so that a block
+// submitted by several peers, or also received via gossip, is only applied once
+type submittedBlocks struct {
+	applied  *RecentIDs
+	inFlight map[string]chan struct{} // Closed once the block is no longer being applied
+	mutex    sync.Mutex
+}
+
+func newSubmittedBlocks() *submittedBlocks {
+	return &submittedBlocks{
+		applied:  NewRecentIDs(submittedBlockCacheSize),
+		inFlight: make(map[string]chan struct{}),
+	}
+}
+
+// begin marks the block as being applied, returning false if it was already applied.
+// If the block is already being applied, begin waits for that to finish first. Returns the
+// context error if the context is done first, since the block was not applied.
+func (s *submittedBlocks) begin(ctx context.Context, id []byte) (bool, error) {
+	for {
+		s.mutex.Lock()
+		if s.applied.Contains(id) {
+			s.mutex.Unlock()
+			return false, nil
+		}
+
+		done, ok := s.inFlight[string(id)]
+		if !ok {
+			s.inFlight[string(id)] = make(chan struct{})
+			s.mutex.Unlock()
+			return true, nil
+		}
+		s.mutex.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// end marks the block as no longer being applied. It is remembered only if it was applied successfully.
+func (s *submittedBlocks) end(id []byte, applied bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if applied {
+		s.applied.Add(id)
+	}
+
+	close(s.inFlight[string(id)])
+	delete(s.inFlight, string(id))
+}
+
+// wasApplied waits for the block to finish being applied, if it is, and returns if it was applied
+func (s *submittedBlocks) wasApplied(ctx context.Context, id []byte) bool {
+	s.mutex.Lock()
+	done, ok := s.inFlight[string(id)]
+	s.mutex.Unlock()
+
+	if ok {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return s.applied.Contains(id)
+}
+
+// PeerSubmissionHandler applies the blocks and transactions that peers submit through the peer rpc service.
+// Submitted transactions count against the same per peer rate limit as gossiped transactions, and failures
+// are reported to the peer error handler like gossip failures.
+type PeerSubmissionHandler struct {
+	localRPC      rpc.LocalRPC
+	rateLimiter   *transactionRateLimiter
+	blocks        *submittedBlocks
+	peerErrorChan chan<- PeerError
+}
+
+// NewPeerSubmissionHandler creates a PeerSubmissionHandler
+func NewPeerSubmissionHandler(localRPC rpc.LocalRPC, peerErrorChan chan<- PeerError, opts *options.GossipOptions) *PeerSubmissionHandler {
+	return &PeerSubmissionHandler{
+		localRPC:      localRPC,
+		rateLimiter:   newTransactionRateLimiter(opts.TransactionRateLimit, opts.TransactionRateBurst),
+		blocks:        newSubmittedBlocks(),
+		peerErrorChan: peerErrorChan,
+	}
+}
+
+// SubmitBlock satisfies the rpc.SubmissionHandler interface
+func (h *PeerSubmissionHandler) SubmitBlock(ctx context.Context, id peer.ID, data []byte) error {
+	err := h.applyBlock(ctx, data)
+	if err != nil {
+		log.Warnf("Submitted block not applied from peer %v: %s", id, err)
+		h.reportError(ctx, id, err)
+	}
+
+	return err
+}
+
+func (h *PeerSubmissionHandler) applyBlock(ctx context.Context, data []byte) error {
+	block := &protocol.Block{}
+	if err := proto.Unmarshal(data, block); err != nil {
+		return fmt.Errorf("%w, %v", p2perrors.ErrDeserialization, err.Error())
+	}
+
+	if block.Id == nil || block.Header == nil {
+		return fmt.Errorf("%w, submitted block missing id or header", p2perrors.ErrDeserialization)
+	}
+
+	// Several peers may submit the same block
+	begun, err := h.blocks.begin(ctx, block.Id)
+	if err != nil || !begun {
+		return err
+	}
+
+	_, err = h.localRPC.ApplyBlock(ctx, block)
+	h.blocks.end(block.Id, err == nil)
+	if err != nil {
+		return blockApplicationError(block, err)
+	}
+
+	log.Infof("Submitted block applied - %s", util.BlockString(block))
+	return nil
+}
+
+// SubmitTransaction satisfies the rpc.SubmissionHandler interface
+func (h *PeerSubmissionHandler) SubmitTransaction(ctx context.Context, id peer.ID, data []byte) error {
+	err := h.applyTransaction(ctx, id, data)
+	if err != nil {
+		log.Warnf("Submitted transaction not applied from peer %v: %s", id, err)
+		h.reportError(ctx, id, err)
+	}
+
+	return err
+}
+
+func (h *PeerSubmissionHandler) applyTransaction(ctx context.Context, id peer.ID, data []byte) error {
+	transaction := &protocol.Transaction{}
+	if err := proto.Unmarshal(data, transaction); err != nil {
+		return fmt.Errorf("%w, %v", p2perrors.ErrDeserialization, err.Error())
+	}
+
+	if transaction.Id == nil {
+		return fmt.Errorf("%w, submitted transaction missing id", p2perrors.ErrDeserialization)
+	}
+
+	if !h.rateLimiter.allow(id, 1, time.Now()) {
+		return fmt.Errorf("%w - %s", p2perrors.ErrTransactionRateLimit, util.TransactionString(transaction))
+	}
+
+	if _, err := h.localRPC.ApplyTransaction(ctx, transaction); err != nil {
+		return fmt.Errorf("%w - %s, %v", p2perrors.ErrTransactionApplication, util.TransactionString(transaction), err.Error())
+	}
+
+	log.Infof("Submitted transaction applied - %s from peer %v", util.TransactionString(transaction), id)
+	return nil
+}
+
+// reportError is synchronous, because the rpc context is canceled once the submission returns.
+// Nothing is reported once the context is done, since the peer stopped waiting on the submission.
+func (h *PeerSubmissionHandler) reportError(ctx context.Context, id peer.ID, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	select {
+	case h.peerErrorChan <- PeerError{id: id, err: err}:
+	case <-ctx.Done():
+	}
+}
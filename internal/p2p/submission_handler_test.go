@@ -0,0 +1,164 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+	"google.golang.org/protobuf/proto"
+)
+
+type testSubmissionRPC struct {
+	rpc.LocalRPC
+	blockErr error
+	applied  int
+}
+
+func (t *testSubmissionRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	if t.blockErr != nil {
+		return nil, t.blockErr
+	}
+	return &chain.SubmitBlockResponse{}, nil
+}
+
+func (t *testSubmissionRPC) ApplyTransaction(ctx context.Context, transaction *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+	t.applied++
+	return &chain.SubmitTransactionResponse{}, nil
+}
+
+// submitAndReceive submits in the background and returns the reported peer error and the submission error
+func submitAndReceive(submit func() error, peerErrorChan <-chan PeerError) (PeerError, error) {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- submit()
+	}()
+
+	peerErr := <-peerErrorChan
+	return peerErr, <-errChan
+}
+
+func TestSubmitBlockError(t *testing.T) {
+	peerErrorChan := make(chan PeerError)
+	local := &testSubmissionRPC{blockErr: errors.New("unknown previous block")}
+	h := NewPeerSubmissionHandler(local, peerErrorChan, options.NewGossipOptions())
+
+	id, _ := multihash.Sum([]byte("block"), multihash.SHA2_256, -1)
+	data, err := proto.Marshal(&protocol.Block{Id: id, Header: &protocol.BlockHeader{Height: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Apply failures are returned to the peer and reported to the error handler
+	peerErr, err := submitAndReceive(func() error {
+		return h.SubmitBlock(context.Background(), peer.ID("peer"), data)
+	}, peerErrorChan)
+
+	if !errors.Is(err, p2perrors.ErrBlockApplication) {
+		t.Errorf("Expected ErrBlockApplication, was %v", err)
+	}
+
+	if peerErr.id != peer.ID("peer") || !errors.Is(peerErr.err, p2perrors.ErrBlockApplication) {
+		t.Errorf("Incorrect peer error reported. Was %v", peerErr)
+	}
+
+	// A block without a header is the peer's fault
+	data, err = proto.Marshal(&protocol.Block{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerErr, _ = submitAndReceive(func() error {
+		return h.SubmitBlock(context.Background(), peer.ID("peer"), data)
+	}, peerErrorChan)
+
+	if !errors.Is(peerErr.err, p2perrors.ErrDeserialization) {
+		t.Errorf("Expected ErrDeserialization, was %v", peerErr.err)
+	}
+}
+
+func TestSubmitTransactionRateLimit(t *testing.T) {
+	peerErrorChan := make(chan PeerError)
+	local := &testSubmissionRPC{}
+	opts := options.NewGossipOptions()
+	opts.TransactionRateLimit = 1
+	opts.TransactionRateBurst = 2
+	h := NewPeerSubmissionHandler(local, peerErrorChan, opts)
+
+	id, _ := multihash.Sum([]byte("transaction"), multihash.SHA2_256, -1)
+	data, err := proto.Marshal(&protocol.Transaction{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = h.SubmitTransaction(context.Background(), peer.ID("peer"), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Submissions over the burst are limited and reported
+	peerErr, err := submitAndReceive(func() error {
+		return h.SubmitTransaction(context.Background(), peer.ID("peer"), data)
+	}, peerErrorChan)
+
+	if !errors.Is(err, p2perrors.ErrTransactionRateLimit) || !errors.Is(peerErr.err, p2perrors.ErrTransactionRateLimit) {
+		t.Errorf("Expected ErrTransactionRateLimit, was %v", err)
+	}
+
+	if local.applied != 2 {
+		t.Errorf("Expected 2 applied transactions, was %v", local.applied)
+	}
+}
+
+func TestSubmitBlockCanceled(t *testing.T) {
+	peerErrorChan := make(chan PeerError, 1)
+	local := &testBlockingRPC{LocalRPC: &testSubmissionRPC{}, release: make(chan struct{})}
+	h := NewPeerSubmissionHandler(local, peerErrorChan, options.NewGossipOptions())
+
+	id, _ := multihash.Sum([]byte("block"), multihash.SHA2_256, -1)
+	data, err := proto.Marshal(&protocol.Block{Id: id, Header: &protocol.BlockHeader{Height: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- h.SubmitBlock(context.Background(), peer.ID("peer"), data)
+	}()
+
+	for i := 0; i < 100; i++ {
+		h.blocks.mutex.Lock()
+		_, ok := h.blocks.inFlight[string(id)]
+		h.blocks.mutex.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// A submission that gives up waiting on the same block in flight was not applied
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err = h.SubmitBlock(ctx, peer.ID("other"), data); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, was %v", err)
+	}
+
+	close(local.release)
+	if err = <-submitted; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case peerErr := <-peerErrorChan:
+		t.Errorf("Unexpected peer error reported: %v", peerErr)
+	default:
+	}
+}
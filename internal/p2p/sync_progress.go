@@ -0,0 +1,165 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/events"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// SyncStatusTopic is the broadcast topic of sync progress, published as an events.SyncStatus
+const SyncStatusTopic = "koinos.p2p.sync_status"
+
+// SyncedBlocks is reported by a PeerConnection after each block request
+type SyncedBlocks struct {
+	id         peer.ID
+	count      uint64
+	headHeight uint64
+}
+
+type syncPeerProgress struct {
+	headHeight uint64
+	blocks     uint64
+	lastReport time.Time
+}
+
+// SyncProgress aggregates the progress of all peer connections and periodically reports it
+type SyncProgress struct {
+	localRPC  rpc.LocalRPC
+	publisher rpc.Publisher // Nil to only log progress
+	opts      options.SyncProgressOptions
+
+	peers      map[peer.ID]*syncPeerProgress
+	lastReport time.Time
+	syncing    bool
+
+	syncedBlocksChan <-chan SyncedBlocks
+}
+
+// NewSyncProgress creates a new SyncProgress
+func NewSyncProgress(localRPC rpc.LocalRPC, publisher rpc.Publisher, syncedBlocksChan <-chan SyncedBlocks, opts options.SyncProgressOptions) *SyncProgress {
+	return &SyncProgress{
+		localRPC:         localRPC,
+		publisher:        publisher,
+		opts:             opts,
+		peers:            make(map[peer.ID]*syncPeerProgress),
+		syncedBlocksChan: syncedBlocksChan,
+	}
+}
+
+func (s *SyncProgress) handleSyncedBlocks(blocks SyncedBlocks, now time.Time) {
+	progress, ok := s.peers[blocks.id]
+	if !ok {
+		progress = &syncPeerProgress{}
+		s.peers[blocks.id] = progress
+	}
+
+	progress.headHeight = blocks.headHeight
+	progress.blocks += blocks.count
+	progress.lastReport = now
+}
+
+// status computes the sync status since the last report and starts a new reporting interval.
+// PeerBlocks holds the blocks synced from each peer since the last report. EtaSeconds is zero when synced.
+func (s *SyncProgress) status(headHeight uint64, now time.Time) *events.SyncStatus {
+	status := &events.SyncStatus{
+		HeadHeight: headHeight,
+		PeerBlocks: make(map[string]uint64),
+	}
+
+	var blocks uint64
+	for id, progress := range s.peers {
+		if now.Sub(progress.lastReport) > s.opts.PeerExpiration {
+			delete(s.peers, id)
+			continue
+		}
+
+		if progress.headHeight > status.BestPeerHeadHeight {
+			status.BestPeerHeadHeight = progress.headHeight
+		}
+
+		if progress.blocks > 0 {
+			status.PeerBlocks[id.Pretty()] = progress.blocks
+			blocks += progress.blocks
+			progress.blocks = 0
+		}
+	}
+
+	if elapsed := now.Sub(s.lastReport); !s.lastReport.IsZero() && elapsed > 0 {
+		status.BlocksPerSecond = float64(blocks) / elapsed.Seconds()
+	}
+	s.lastReport = now
+
+	status.Syncing = status.BestPeerHeadHeight > headHeight+s.opts.SyncedBlockDelta
+	if status.Syncing && status.BlocksPerSecond > 0 {
+		status.EtaSeconds = uint64(float64(status.BestPeerHeadHeight-headHeight) / status.BlocksPerSecond)
+	}
+
+	return status
+}
+
+func (s *SyncProgress) report(ctx context.Context) {
+	rpcContext, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	headInfo, err := s.localRPC.GetHeadBlock(rpcContext)
+	if err != nil || headInfo.HeadTopology == nil {
+		log.Debugf("Could not get head block for sync progress: %v", err)
+		return
+	}
+
+	status := s.status(headInfo.HeadTopology.Height, time.Now())
+
+	if status.Syncing {
+		log.Infof("Syncing - head %v of %v, %.1f blocks/s, ETA %s",
+			status.HeadHeight,
+			status.BestPeerHeadHeight,
+			status.BlocksPerSecond,
+			(time.Duration(status.EtaSeconds) * time.Second).String())
+		for id, blocks := range status.PeerBlocks {
+			log.Debugf("Synced %v blocks from peer %s", blocks, id)
+		}
+	} else if s.syncing {
+		log.Infof("Sync complete - head %v", status.HeadHeight)
+	}
+	s.syncing = status.Syncing
+
+	if s.publisher == nil {
+		return
+	}
+
+	data, err := proto.Marshal(status)
+	if err != nil {
+		log.Warnf("Error serializing sync status: %s", err)
+		return
+	}
+
+	if err = s.publisher.Broadcast("application/octet-stream", SyncStatusTopic, data); err != nil {
+		log.Warnf("Error broadcasting sync status: %s", err)
+	}
+}
+
+// Start tracking sync progress
+func (s *SyncProgress) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.opts.ReportInterval)
+		defer ticker.Stop()
+
+		s.lastReport = time.Now()
+
+		for {
+			select {
+			case blocks := <-s.syncedBlocksChan:
+				s.handleSyncedBlocks(blocks, time.Now())
+			case <-ticker.C:
+				s.report(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,100 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/events"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-p2p/internal/testutil"
+	"github.com/koinos/koinos-proto-golang/koinos"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+type testHeadRPC struct {
+	rpc.LocalRPC
+	height uint64
+}
+
+func (t *testHeadRPC) GetHeadBlock(ctx context.Context) (*chain.GetHeadInfoResponse, error) {
+	return &chain.GetHeadInfoResponse{HeadTopology: &koinos.BlockTopology{Height: t.height}}, nil
+}
+
+func TestSyncProgressStatus(t *testing.T) {
+	opts := options.NewSyncProgressOptions()
+	opts.SyncedBlockDelta = 5
+
+	s := NewSyncProgress(nil, nil, nil, *opts)
+
+	start := time.Now()
+	s.lastReport = start
+
+	s.handleSyncedBlocks(SyncedBlocks{id: peer.ID("a"), count: 300, headHeight: 2000}, start)
+	s.handleSyncedBlocks(SyncedBlocks{id: peer.ID("b"), count: 200, headHeight: 1500}, start)
+	s.handleSyncedBlocks(SyncedBlocks{id: peer.ID("a"), count: 500, headHeight: 2000}, start)
+
+	status := s.status(1000, start.Add(time.Second*10))
+	if !status.Syncing {
+		t.Errorf("Expected node to be syncing")
+	}
+
+	if status.BestPeerHeadHeight != 2000 {
+		t.Errorf("Incorrect best peer head height. Expected 2000, was %v", status.BestPeerHeadHeight)
+	}
+
+	if status.BlocksPerSecond != 100 {
+		t.Errorf("Incorrect blocks per second. Expected 100, was %v", status.BlocksPerSecond)
+	}
+
+	if status.EtaSeconds != 10 {
+		t.Errorf("Incorrect ETA. Expected 10, was %v", status.EtaSeconds)
+	}
+
+	if status.PeerBlocks[peer.ID("a").Pretty()] != 800 || status.PeerBlocks[peer.ID("b").Pretty()] != 200 {
+		t.Errorf("Incorrect peer contributions. Was %v", status.PeerBlocks)
+	}
+
+	// Contributions are reset each report and expired peers are forgotten
+	s.handleSyncedBlocks(SyncedBlocks{id: peer.ID("b"), count: 0, headHeight: 1998}, start.Add(time.Minute*2))
+	status = s.status(1995, start.Add(time.Minute*2))
+	if status.Syncing || status.EtaSeconds != 0 {
+		t.Errorf("Expected node to be synced")
+	}
+
+	if status.BestPeerHeadHeight != 1998 {
+		t.Errorf("Incorrect best peer head height after peer expired. Expected 1998, was %v", status.BestPeerHeadHeight)
+	}
+
+	if len(status.PeerBlocks) != 0 || status.BlocksPerSecond != 0 {
+		t.Errorf("Expected no blocks synced since the last report")
+	}
+}
+
+func TestSyncProgressBroadcast(t *testing.T) {
+	publisher := &testutil.TestPublisher{}
+	s := NewSyncProgress(&testHeadRPC{height: 10}, publisher, nil, *options.NewSyncProgressOptions())
+	s.handleSyncedBlocks(SyncedBlocks{id: peer.ID("a"), count: 0, headHeight: 100}, time.Now())
+
+	s.report(context.Background())
+
+	if len(publisher.Topics) != 1 || publisher.Topics[0] != SyncStatusTopic {
+		t.Fatalf("Expected sync status broadcast. Was %v", publisher.Topics)
+	}
+
+	if publisher.ContentTypes[0] != "application/octet-stream" {
+		t.Errorf("Expected sync status to be broadcast as protobuf. Was %v", publisher.ContentTypes[0])
+	}
+
+	status := &events.SyncStatus{}
+	if err := proto.Unmarshal(publisher.Messages[0], status); err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Syncing || status.HeadHeight != 10 || status.BestPeerHeadHeight != 100 {
+		t.Errorf("Incorrect sync status. Was %+v", status)
+	}
+}
@@ -0,0 +1,118 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	log "github.com/koinos/koinos-log-golang"
+	"github.com/koinos/koinos-p2p/internal/messages"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// messagePublisher publishes a message to a gossip topic
+type messagePublisher interface {
+	PublishMessage(ctx context.Context, bytes []byte) error
+}
+
+// transactionBatcher collects serialized transactions and publishes them as a single batch message,
+// once the batch is full or its oldest transaction has waited for the max delay. A batch is full when it
+// holds the max number of transactions, or when the next transaction would make it larger than the max bytes.
+type transactionBatcher struct {
+	gossip messagePublisher
+	opts   *options.GossipOptions
+
+	transactionChan chan []byte
+}
+
+func newTransactionBatcher(gossip messagePublisher, opts *options.GossipOptions) *transactionBatcher {
+	return &transactionBatcher{
+		gossip:          gossip,
+		opts:            opts,
+		transactionChan: make(chan []byte, opts.TransactionBatchMaxSize),
+	}
+}
+
+// add queues a transaction for the next batch
+func (b *transactionBatcher) add(ctx context.Context, data []byte) error {
+	select {
+	case b.transactionChan <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *transactionBatcher) publish(ctx context.Context, transactions [][]byte) {
+	data, err := proto.Marshal(&messages.TransactionBatch{Transactions: transactions})
+	if err != nil {
+		log.Warnf("Unable to serialize transaction batch: %s", err)
+		return
+	}
+
+	if err = b.gossip.PublishMessage(ctx, data); err != nil {
+		log.Warnf("Unable to publish batch of %v transactions: %s", len(transactions), err)
+		return
+	}
+
+	log.Debugf("Published batch of %v transactions", len(transactions))
+}
+
+// batchEntrySize returns the number of bytes a serialized transaction adds to a serialized batch
+func batchEntrySize(data []byte) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(data))
+}
+
+func (b *transactionBatcher) start(ctx context.Context) {
+	go func() {
+		batch := make([][]byte, 0, b.opts.TransactionBatchMaxSize)
+		batchBytes := 0
+
+		timer := time.NewTimer(b.opts.TransactionBatchMaxDelay)
+		timer.Stop()
+		defer timer.Stop()
+
+		stopTimer := func() {
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		flush := func() {
+			b.publish(ctx, batch)
+			batch = make([][]byte, 0, b.opts.TransactionBatchMaxSize)
+			batchBytes = 0
+		}
+
+		for {
+			select {
+			case data := <-b.transactionChan:
+				size := batchEntrySize(data)
+
+				// Publish the current batch first if the transaction would make it too large
+				if len(batch) > 0 && batchBytes+size > b.opts.TransactionBatchMaxBytes {
+					stopTimer()
+					flush()
+				}
+
+				if len(batch) == 0 {
+					timer.Reset(b.opts.TransactionBatchMaxDelay)
+				}
+				batch = append(batch, data)
+				batchBytes += size
+
+				if len(batch) < b.opts.TransactionBatchMaxSize && batchBytes < b.opts.TransactionBatchMaxBytes {
+					continue
+				}
+
+				stopTimer()
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+
+			flush()
+		}
+	}()
+}
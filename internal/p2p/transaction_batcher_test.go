@@ -0,0 +1,75 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/koinos/koinos-p2p/internal/messages"
+	"github.com/koinos/koinos-p2p/internal/options"
+	"google.golang.org/protobuf/proto"
+)
+
+type testMessagePublisher struct {
+	messages chan []byte
+}
+
+func (t *testMessagePublisher) PublishMessage(ctx context.Context, bytes []byte) error {
+	t.messages <- bytes
+	return nil
+}
+
+func TestTransactionBatcherMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := options.NewGossipOptions()
+	opts.TransactionBatchMaxSize = 10
+	opts.TransactionBatchMaxBytes = 250
+	opts.TransactionBatchMaxDelay = time.Millisecond * 50
+
+	publisher := &testMessagePublisher{messages: make(chan []byte, 10)}
+	batcher := newTransactionBatcher(publisher, opts)
+	batcher.start(ctx)
+
+	// Two transactions fit in a batch, the third and the oversized fourth are each published alone
+	transactions := [][]byte{
+		bytes.Repeat([]byte{1}, 100),
+		bytes.Repeat([]byte{2}, 100),
+		bytes.Repeat([]byte{3}, 100),
+		bytes.Repeat([]byte{4}, 300),
+	}
+	for _, data := range transactions {
+		if err := batcher.add(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := [][][]byte{transactions[:2], transactions[2:3], transactions[3:]}
+	for i, expectedBatch := range expected {
+		select {
+		case data := <-publisher.messages:
+			batch := &messages.TransactionBatch{}
+			if err := proto.Unmarshal(data, batch); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(batch.Transactions) != len(expectedBatch) {
+				t.Fatalf("Expected batch %v to hold %v transactions, was %v", i, len(expectedBatch), len(batch.Transactions))
+			}
+
+			for j := range expectedBatch {
+				if !bytes.Equal(batch.Transactions[j], expectedBatch[j]) {
+					t.Errorf("Unexpected transaction %v in batch %v", j, i)
+				}
+			}
+
+			if len(batch.Transactions) > 1 && len(data) > opts.TransactionBatchMaxBytes {
+				t.Errorf("Expected batch %v to be at most %v bytes, was %v", i, opts.TransactionBatchMaxBytes, len(data))
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected batch %v to be published", i)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// rateLimiterPruneInterval is how often the buckets of idle peers are forgotten
+const rateLimiterPruneInterval = time.Minute
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// transactionRateLimiter limits the transactions accepted from each peer with a token bucket,
+// refilled at rate tokens per second up to burst tokens
+type transactionRateLimiter struct {
+	rate  float64 // 0 for no limit
+	burst float64
+
+	buckets   map[peer.ID]*tokenBucket
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+func newTransactionRateLimiter(rate float64, burst uint64) *transactionRateLimiter {
+	return &transactionRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[peer.ID]*tokenBucket),
+	}
+}
+
+// allow takes count tokens from the peer's bucket, returning false without taking any if there are not enough
+func (l *transactionRateLimiter) allow(id peer.ID, count int, now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.prune(now)
+
+	bucket, ok := l.buckets[id]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastRefill: now}
+		l.buckets[id] = bucket
+	}
+
+	l.refill(bucket, now)
+
+	if bucket.tokens < float64(count) {
+		return false
+	}
+
+	bucket.tokens -= float64(count)
+	return true
+}
+
+func (l *transactionRateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.lastRefill); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * l.rate
+		if bucket.tokens > l.burst {
+			bucket.tokens = l.burst
+		}
+		bucket.lastRefill = now
+	}
+}
+
+// prune forgets full buckets, which are the same as new buckets
+func (l *transactionRateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateLimiterPruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for id, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, id)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestTransactionRateLimiter(t *testing.T) {
+	l := newTransactionRateLimiter(10, 20)
+	now := time.Now()
+
+	if !l.allow(peer.ID("a"), 20, now) {
+		t.Errorf("Expected burst to be allowed")
+	}
+
+	if l.allow(peer.ID("a"), 1, now) {
+		t.Errorf("Expected transaction over the burst to be limited")
+	}
+
+	// Peers are limited independently
+	if !l.allow(peer.ID("b"), 5, now) {
+		t.Errorf("Expected transactions from another peer to be allowed")
+	}
+
+	// Tokens refill at the rate
+	if !l.allow(peer.ID("a"), 5, now.Add(time.Millisecond*500)) {
+		t.Errorf("Expected refilled tokens to be allowed")
+	}
+
+	if l.allow(peer.ID("a"), 1, now.Add(time.Millisecond*500)) {
+		t.Errorf("Expected transaction to be limited after using refilled tokens")
+	}
+
+	// A batch larger than the available tokens is limited as a whole without taking tokens
+	if l.allow(peer.ID("a"), 15, now.Add(time.Second)) {
+		t.Errorf("Expected batch over the available tokens to be limited")
+	}
+
+	if !l.allow(peer.ID("a"), 5, now.Add(time.Second)) {
+		t.Errorf("Expected tokens not to be taken by a limited batch")
+	}
+
+	// Refilled buckets are forgotten
+	l.allow(peer.ID("c"), 1, now.Add(time.Hour))
+	if _, ok := l.buckets[peer.ID("a")]; ok {
+		t.Errorf("Expected idle peer's bucket to be pruned")
+	}
+
+	// A zero rate does not limit
+	l = newTransactionRateLimiter(0, 0)
+	if !l.allow(peer.ID("a"), 1000, now) {
+		t.Errorf("Expected no limit with zero rate")
+	}
+}
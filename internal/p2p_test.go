@@ -2,6 +2,10 @@ package p2p
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -9,11 +13,14 @@ import (
 	"github.com/koinos/koinos-p2p/internal/node"
 	"github.com/koinos/koinos-p2p/internal/options"
 	"github.com/koinos/koinos-p2p/internal/rpc"
+	"github.com/koinos/koinos-p2p/internal/testutil"
 	"github.com/koinos/koinos-proto-golang/koinos"
 	"github.com/koinos/koinos-proto-golang/koinos/protocol"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/mempool"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
 )
@@ -30,6 +37,9 @@ type TestRPC struct {
 	ApplyBlocks      int    // Number of blocks to apply before failure. < 0 = always apply
 	BlocksApplied    []*protocol.Block
 	BlocksByID       map[string]*protocol.Block
+	PendingTrxs      []*protocol.Transaction
+	TrxsApplied      []*protocol.Transaction
+	ChainUnavailable bool
 	Mutex            sync.Mutex
 }
 
@@ -102,10 +112,36 @@ func (k *TestRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain
 	return &chain.SubmitBlockResponse{}, nil
 }
 
-func (k *TestRPC) ApplyTransaction(ctx context.Context, block *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+func (k *TestRPC) ApplyTransaction(ctx context.Context, trx *protocol.Transaction) (*chain.SubmitTransactionResponse, error) {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.TrxsApplied = append(k.TrxsApplied, trx)
+
 	return &chain.SubmitTransactionResponse{}, nil
 }
 
+func (k *TestRPC) ApplyTransactions(ctx context.Context, trxs []*protocol.Transaction) []error {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.TrxsApplied = append(k.TrxsApplied, trxs...)
+
+	return make([]error, len(trxs))
+}
+
+func (k *TestRPC) GetPendingTransactions(ctx context.Context, limit uint64) (*mempool.GetPendingTransactionsResponse, error) {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	resp := &mempool.GetPendingTransactionsResponse{}
+	for i := 0; i < len(k.PendingTrxs) && uint64(i) < limit; i++ {
+		resp.Transactions = append(resp.Transactions, k.PendingTrxs[i])
+	}
+
+	return resp, nil
+}
+
 func (k *TestRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (*block_store.GetBlocksByIdResponse, error) {
 	k.Mutex.Lock()
 	defer k.Mutex.Unlock()
@@ -172,7 +208,30 @@ func (k *TestRPC) IsConnectedToBlockStore(ctx context.Context) (bool, error) {
 }
 
 func (k *TestRPC) IsConnectedToChain(ctx context.Context) (bool, error) {
-	return true, nil
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	return !k.ChainUnavailable, nil
+}
+
+func (k *TestRPC) setChainUnavailable(unavailable bool) {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.ChainUnavailable = unavailable
+}
+
+// resync resets the chain to the given height, as if it had been restarted and resynced
+func (k *TestRPC) resync(height uint64) {
+	fresh := NewTestRPC(height)
+
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	k.Height = fresh.Height
+	k.LastIrreversible = fresh.LastIrreversible
+	k.BlocksApplied = fresh.BlocksApplied
+	k.BlocksByID = fresh.BlocksByID
 }
 
 func NewTestRPC(height uint64) *TestRPC {
@@ -190,6 +249,8 @@ func NewTestRPC(height uint64) *TestRPC {
 		ApplyBlocks:      -1,
 		BlocksApplied:    make([]*protocol.Block, 0),
 		BlocksByID:       make(map[string]*protocol.Block),
+		PendingTrxs:      make([]*protocol.Transaction, 0),
+		TrxsApplied:      make([]*protocol.Transaction, 0),
 	}
 
 	for h := uint64(1); h <= height; h++ {
@@ -205,13 +266,13 @@ func SetUnitTestOptions(config *options.Config) {
 }
 
 func createTestClients(listenRPC rpc.LocalRPC, listenConfig *options.Config, sendRPC rpc.LocalRPC, sendConfig *options.Config) (*node.KoinosP2PNode, *node.KoinosP2PNode, multiaddr.Multiaddr, multiaddr.Multiaddr, error) {
-	listenNode, err := node.NewKoinosP2PNode(context.Background(), "/ip4/127.0.0.1/tcp/8765", listenRPC, nil, "test1", listenConfig)
+	listenNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8765"}, listenRPC, nil, nil, "test1", listenConfig)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 	listenNode.Start(context.Background())
 
-	sendNode, err := node.NewKoinosP2PNode(context.Background(), "/ip4/127.0.0.1/tcp/8888", sendRPC, nil, "test2", sendConfig)
+	sendNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8888"}, sendRPC, nil, nil, "test2", sendConfig)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
@@ -332,3 +393,352 @@ func TestCheckpointFailure(t *testing.T) {
 		t.Errorf("Incorrect number of blocks applied, expected %d, got %d", expectedBlocksApplied, len(sendRPC.BlocksApplied))
 	}
 }
+
+func TestSyncPendingTransactions(t *testing.T) {
+	listenRPC := NewTestRPC(128)
+	sendRPC := NewTestRPC(5)
+	for i := uint64(0); i < 250; i++ {
+		trxID, _ := multihash.Encode(make([]byte, 0), i)
+		listenRPC.PendingTrxs = append(listenRPC.PendingTrxs, &protocol.Transaction{Id: trxID})
+	}
+	// Duplicate transactions should only be applied once
+	listenRPC.PendingTrxs = append(listenRPC.PendingTrxs, listenRPC.PendingTrxs[0])
+
+	listenNode, sendNode, addr, _, err := createTestClients(listenRPC, options.NewConfig(), sendRPC, options.NewConfig())
+	if err != nil {
+		t.Error(err)
+	}
+	defer listenNode.Close()
+	defer sendNode.Close()
+
+	p, _ := peer.AddrInfoFromP2pAddr(addr)
+	err = sendNode.ConnectToPeerAddress(context.Background(), p)
+	if err != nil {
+		t.Error(err)
+	}
+
+	trxsApplied := func() int {
+		sendRPC.Mutex.Lock()
+		defer sendRPC.Mutex.Unlock()
+		return len(sendRPC.TrxsApplied)
+	}
+
+	for i := 0; i < 100 && trxsApplied() < 250; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// Give a duplicate the chance to be applied
+	time.Sleep(time.Millisecond * 100)
+
+	sendRPC.Mutex.Lock()
+	defer sendRPC.Mutex.Unlock()
+
+	if len(sendRPC.TrxsApplied) != 250 {
+		t.Errorf("Incorrect number of transactions applied. Expected 250, was %v", len(sendRPC.TrxsApplied))
+	}
+}
+
+func TestSyncDeferredUntilChainAvailable(t *testing.T) {
+	listenRPC := NewTestRPC(128)
+	sendRPC := NewTestRPC(5)
+	sendRPC.ChainUnavailable = true
+
+	sendConfig := options.NewConfig()
+	sendConfig.NodeOptions.LocalServiceCheckInterval = time.Millisecond * 50
+	sendConfig.NodeOptions.LocalServiceMaxBackoff = time.Millisecond * 50
+
+	listenNode, sendNode, addr, _, err := createTestClients(listenRPC, options.NewConfig(), sendRPC, sendConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenNode.Close()
+	defer sendNode.Close()
+
+	// Networking starts without the chain, but sync does not
+	p, _ := peer.AddrInfoFromP2pAddr(addr)
+	if err = sendNode.ConnectToPeerAddress(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	if sendNode.LocalServicesAvailable() {
+		t.Errorf("Expected local services to be unavailable")
+	}
+
+	if sendRPC.blocksApplied() != 0 {
+		t.Errorf("Incorrect number of blocks applied. Expected 0, was %v", sendRPC.blocksApplied())
+	}
+
+	// Sync resumes once the chain is available
+	sendRPC.setChainUnavailable(false)
+
+	for i := 0; i < 30 && sendRPC.blocksApplied() < 123; i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if sendRPC.blocksApplied() != 123 {
+		t.Errorf("Incorrect number of blocks applied. Expected 123, was %v", sendRPC.blocksApplied())
+	}
+
+	// Sync with peers is paused when the chain becomes unavailable again
+	sendRPC.setChainUnavailable(true)
+	time.Sleep(time.Millisecond * 200)
+
+	if sendNode.LocalServicesAvailable() {
+		t.Errorf("Expected local services to be unavailable")
+	}
+
+	for _, status := range sendNode.ConnectionManager.GetPeerStatus(context.Background()) {
+		if status.Handshaked {
+			t.Errorf("Expected sync with peer %v to be paused", status.ID)
+		}
+	}
+}
+
+func TestChainRestart(t *testing.T) {
+	listenRPC := NewTestRPC(128)
+	sendRPC := NewTestRPC(5)
+
+	sendConfig := options.NewConfig()
+	sendConfig.NodeOptions.LocalServiceCheckInterval = time.Millisecond * 50
+
+	listenNode, sendNode, addr, _, err := createTestClients(listenRPC, options.NewConfig(), sendRPC, sendConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenNode.Close()
+	defer sendNode.Close()
+
+	p, _ := peer.AddrInfoFromP2pAddr(addr)
+	if err = sendNode.ConnectToPeerAddress(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 30 && sendRPC.blocksApplied() < 123; i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if sendRPC.blocksApplied() != 123 {
+		t.Fatalf("Incorrect number of blocks applied. Expected 123, was %v", sendRPC.blocksApplied())
+	}
+
+	// After the chain is resynced from scratch, the node reloads its LIB and syncs from it again
+	sendRPC.resync(5)
+
+	for i := 0; i < 30 && sendRPC.blocksApplied() < 123; i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if sendRPC.blocksApplied() != 123 {
+		t.Errorf("Incorrect number of blocks applied after restart. Expected 123, was %v", sendRPC.blocksApplied())
+	}
+}
+
+// slowApplyRPC delays block application so that blocks are being applied during shutdown
+type slowApplyRPC struct {
+	*TestRPC
+	delay time.Duration
+}
+
+func (k *slowApplyRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error) {
+	time.Sleep(k.delay)
+	return k.TestRPC.ApplyBlock(ctx, block)
+}
+
+func (k *TestRPC) blocksApplied() int {
+	k.Mutex.Lock()
+	defer k.Mutex.Unlock()
+
+	return len(k.BlocksApplied)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	goroutines := testutil.GoroutineCount()
+
+	listenRPC := NewTestRPC(128)
+	sendRPC := NewTestRPC(5)
+
+	listenNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8765"}, listenRPC, nil, nil, "test1", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenHandle := listenNode.Start(context.Background())
+
+	sendNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8888"}, &slowApplyRPC{TestRPC: sendRPC, delay: time.Millisecond * 100}, nil, nil, "test2", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendHandle := sendNode.Start(context.Background())
+
+	p, _ := peer.AddrInfoFromP2pAddr(listenNode.GetAddress())
+	if err = sendNode.ConnectToPeerAddress(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop while the sending node is still syncing
+	for sendRPC.blocksApplied() < 3 {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if err = sendHandle.Stop(ctx); err != nil {
+		t.Error(err)
+	}
+
+	// The in-flight block finished applying before stop returned, and no blocks are applied after
+	applied := sendRPC.blocksApplied()
+	time.Sleep(time.Millisecond * 500)
+
+	if sendRPC.blocksApplied() != applied {
+		t.Errorf("Blocks were applied after shutdown. Expected %v, was %v", applied, sendRPC.blocksApplied())
+	}
+
+	if applied >= 123 {
+		t.Errorf("Expected shutdown to interrupt sync, but all blocks were applied")
+	}
+
+	if err = listenHandle.Stop(ctx); err != nil {
+		t.Error(err)
+	}
+
+	testutil.WaitForGoroutines(t, goroutines)
+}
+
+func writePrivateNetworkKey(t *testing.T, dir string) string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "swarm.key")
+	if err := ioutil.WriteFile(keyFile, []byte("/key/swarm/psk/1.0.0/\n/base16/\n"+hex.EncodeToString(key)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return keyFile
+}
+
+func TestPrivateNetwork(t *testing.T) {
+	keyFile := writePrivateNetworkKey(t, t.TempDir())
+
+	listenConfig := options.NewConfig()
+	listenConfig.NodeOptions.PrivateNetworkKeyFile = keyFile
+	sendConfig := options.NewConfig()
+	sendConfig.NodeOptions.PrivateNetworkKeyFile = keyFile
+
+	listenRPC := NewTestRPC(128)
+	sendRPC := NewTestRPC(5)
+	listenNode, sendNode, addr, _, err := createTestClients(listenRPC, listenConfig, sendRPC, sendConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenNode.Close()
+	defer sendNode.Close()
+
+	outsideRPC := NewTestRPC(5)
+	outsideNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8766"}, outsideRPC, nil, nil, "test3", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outsideNode.Close()
+	outsideNode.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	// Nodes sharing the key can connect and sync
+	p, _ := peer.AddrInfoFromP2pAddr(addr)
+	if err = sendNode.ConnectToPeerAddress(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	// A node without the key cannot connect in either direction
+	if err = outsideNode.ConnectToPeerAddress(ctx, p); err == nil {
+		t.Error("Node without the private network key connected to a private node")
+	}
+
+	p, _ = peer.AddrInfoFromP2pAddr(outsideNode.GetAddress())
+	if err = sendNode.ConnectToPeerAddress(ctx, p); err == nil {
+		t.Error("Private node connected to a node without the private network key")
+	}
+
+	time.Sleep(time.Duration(3000) * time.Duration(time.Millisecond))
+
+	if sendRPC.blocksApplied() != 123 {
+		t.Errorf("Incorrect number of blocks applied. Expected 123, was %v", sendRPC.blocksApplied())
+	}
+
+	if outsideRPC.blocksApplied() != 0 {
+		t.Errorf("Node without the private network key applied blocks. Expected 0, was %v", outsideRPC.blocksApplied())
+	}
+}
+
+func TestSentryMode(t *testing.T) {
+	// The producer's ID is needed before the sentry is created
+	producerRPC := NewTestRPC(5)
+	producerNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8888"}, producerRPC, nil, nil, "test2", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producerID := producerNode.Host.ID()
+	producerNode.Close()
+
+	sentryConfig := options.NewConfig()
+	sentryConfig.NodeOptions.PrivatePeers = []string{producerID.Pretty()}
+	sentryNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8765"}, NewTestRPC(128), nil, nil, "test1", sentryConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sentryNode.Close()
+	sentryNode.Start(context.Background())
+
+	outsideNode, err := node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8766"}, NewTestRPC(128), nil, nil, "test3", options.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outsideNode.Close()
+	outsideNode.Start(context.Background())
+
+	producerConfig := options.NewConfig()
+	producerConfig.NodeOptions.SentryMode = true
+	producerConfig.NodeOptions.InitialPeers = []string{sentryNode.GetAddress().String()}
+	producerNode, err = node.NewKoinosP2PNode(context.Background(), []string{"/ip4/127.0.0.1/tcp/8888"}, producerRPC, nil, nil, "test2", producerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producerNode.Close()
+	producerNode.Start(context.Background())
+
+	// The producer connects to its sentry and syncs through it
+	time.Sleep(time.Duration(3000) * time.Duration(time.Millisecond))
+
+	if producerRPC.blocksApplied() != 123 {
+		t.Errorf("Incorrect number of blocks applied. Expected 123, was %v", producerRPC.blocksApplied())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	// No other peer can connect to the producer, and the producer does not connect to other peers
+	p, _ := peer.AddrInfoFromP2pAddr(producerNode.GetAddress())
+	if err = outsideNode.ConnectToPeerAddress(ctx, p); err == nil {
+		t.Error("Outside node connected to a node in sentry mode")
+	}
+
+	p, _ = peer.AddrInfoFromP2pAddr(outsideNode.GetAddress())
+	if err = producerNode.ConnectToPeerAddress(ctx, p); err == nil {
+		t.Error("Node in sentry mode connected to a peer that is not its sentry")
+	}
+
+	// The sentry never learns the producer's addresses, so it cannot share them
+	if addrs := sentryNode.Host.Peerstore().Addrs(producerID); len(addrs) != 0 {
+		t.Errorf("Sentry stored the addresses of a private peer: %v", addrs)
+	}
+
+	if sentryNode.Host.Peerstore().(peerstore.CertifiedAddrBook).GetPeerRecord(producerID) != nil {
+		t.Error("Sentry stored the peer record of a private peer")
+	}
+}
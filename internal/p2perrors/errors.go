@@ -2,6 +2,7 @@ package p2perrors
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -17,9 +18,33 @@ var (
 	// ErrBlockApplication represents any error applying the block in chain
 	ErrBlockApplication = errors.New("block application failed")
 
+	// ErrUnknownPreviousBlock is when the chain does not know the block's parent
+	ErrUnknownPreviousBlock = fmt.Errorf("%w, unknown previous block", ErrBlockApplication)
+
+	// ErrInvalidBlockSignature is when the chain rejects the block's signature
+	ErrInvalidBlockSignature = fmt.Errorf("%w, invalid block signature", ErrBlockApplication)
+
+	// ErrDuplicateBlock is when the chain has already applied the block
+	ErrDuplicateBlock = fmt.Errorf("%w, duplicate block", ErrBlockApplication)
+
+	// ErrInvalidBlockTransaction is when the chain rejects a transaction contained in the block
+	ErrInvalidBlockTransaction = fmt.Errorf("%w, invalid transaction in block", ErrBlockApplication)
+
+	// ErrBlockResourceLimit is when the block exceeds a resource limit
+	ErrBlockResourceLimit = fmt.Errorf("%w, block exceeds resource limits", ErrBlockApplication)
+
+	// ErrOrphanBlock is when a block's parent is unknown and the block is held until the parent arrives
+	ErrOrphanBlock = errors.New("block parent is unknown")
+
 	// ErrTransactionApplication represents any error applying a transaction to the mem pool
 	ErrTransactionApplication = errors.New("transaction application failed")
 
+	// ErrDuplicateTransaction is when the transaction is already in the mempool or on chain
+	ErrDuplicateTransaction = errors.New("transaction already known")
+
+	// ErrTransactionRateLimit is when a peer gossips transactions faster than the rate limit
+	ErrTransactionRateLimit = errors.New("transaction rate limit exceeded")
+
 	// ErrChainIDMismatch represents the peer has a different chain id
 	ErrChainIDMismatch = errors.New("chain id does not match peer's")
 
@@ -32,9 +57,15 @@ var (
 	// ErrLocalRPC represents an error occurred during a local rpc
 	ErrLocalRPC = errors.New("local RPC error")
 
+	// ErrShuttingDown is when a local rpc is rejected because the node is shutting down
+	ErrShuttingDown = fmt.Errorf("%w, node is shutting down", ErrLocalRPC)
+
 	// ErrPeerRPC represents an error occurred during a peer rpc
 	ErrPeerRPC = errors.New("peer RPC error")
 
+	// ErrBlockNotFound is when a peer does not return a requested block
+	ErrBlockNotFound = fmt.Errorf("%w, block not found", ErrPeerRPC)
+
 	// ErrLocalRPCTimeout represents a local rpc timed out
 	ErrLocalRPCTimeout = errors.New("local RPC request timed out")
 
@@ -43,4 +74,10 @@ var (
 
 	// ErrProcessRequestTimeout represents an in process asynchronous request time out
 	ErrProcessRequestTimeout = errors.New("in process request timed out")
+
+	// ErrGossipDisabled is when a message cannot be published because gossip is disabled
+	ErrGossipDisabled = errors.New("gossip is disabled")
+
+	// ErrNoPeers is when there are no peers to send a message to
+	ErrNoPeers = errors.New("no peers available")
 )
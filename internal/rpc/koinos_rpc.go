@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/btcsuite/btcd/btcec"
 	"google.golang.org/protobuf/proto"
 
 	koinosmq "github.com/koinos/koinos-mq-golang"
@@ -13,6 +16,7 @@ import (
 	"github.com/koinos/koinos-proto-golang/koinos/rpc"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/mempool"
 	"github.com/multiformats/go-multihash"
 )
 
@@ -20,8 +24,59 @@ import (
 const (
 	ChainRPC      = "chain"
 	BlockStoreRPC = "block_store"
+	MempoolRPC    = "mempool"
 )
 
+// maxConcurrentPayers is the maximum number of payers whose transactions ApplyTransactions applies at once
+const maxConcurrentPayers = 16
+
+type chainErrorClassification struct {
+	substrings []string
+	err        error
+}
+
+// blockErrorClassifications map chain error messages to errors, checked in order. Phrases that other
+// phrases may contain come first (e.g. "insufficient signatures" is a signature error, not a resource
+// error), and no class matches on a bare word such as "transaction" that appears in unrelated messages.
+var blockErrorClassifications = []chainErrorClassification{
+	{[]string{"unknown previous", "previous block does not exist", "unlinkable"}, p2perrors.ErrUnknownPreviousBlock},
+	{[]string{"duplicate block", "block already exists", "already applied"}, p2perrors.ErrDuplicateBlock},
+	{[]string{"signature", "signer"}, p2perrors.ErrInvalidBlockSignature},
+	{[]string{"invalid transaction", "nonce"}, p2perrors.ErrInvalidBlockTransaction},
+	{[]string{"resource", "mana", "limit exceeded", "insufficient"}, p2perrors.ErrBlockResourceLimit},
+}
+
+// classifyBlockError returns the error best describing a chain error when applying a block
+func classifyBlockError(chainErr *rpc.ErrorResponse) error {
+	message := strings.ToLower(chainErr.GetMessage())
+
+	for _, classification := range blockErrorClassifications {
+		for _, substring := range classification.substrings {
+			if strings.Contains(message, substring) {
+				return classification.err
+			}
+		}
+	}
+
+	return p2perrors.ErrBlockApplication
+}
+
+// duplicateTransactionSubstrings are the chain error messages for a transaction that is already known
+var duplicateTransactionSubstrings = []string{"duplicate transaction", "transaction already exists", "already in mempool", "already applied"}
+
+// classifyTransactionError returns the error best describing a chain error when applying a transaction
+func classifyTransactionError(chainErr *rpc.ErrorResponse) error {
+	message := strings.ToLower(chainErr.GetMessage())
+
+	for _, substring := range duplicateTransactionSubstrings {
+		if strings.Contains(message, substring) {
+			return p2perrors.ErrDuplicateTransaction
+		}
+	}
+
+	return p2perrors.ErrLocalRPC
+}
+
 // KoinosRPC implements LocalRPC implementation by communicating with a local Koinos node via AMQP
 type KoinosRPC struct {
 	mq *koinosmq.Client
@@ -115,7 +170,7 @@ func (k *KoinosRPC) ApplyBlock(ctx context.Context, block *protocol.Block) (*cha
 	case *chain.ChainResponse_SubmitBlock:
 		response = t.SubmitBlock
 	case *chain.ChainResponse_Error:
-		err = fmt.Errorf("%w ApplyBlock, chain rpc error, %s", p2perrors.ErrLocalRPC, string(t.Error.GetMessage()))
+		err = fmt.Errorf("%w ApplyBlock, chain rpc error, %s", classifyBlockError(t.Error), string(t.Error.GetMessage()))
 	default:
 		err = fmt.Errorf("%w ApplyBlock, unexpected chain rpc response", p2perrors.ErrLocalRPC)
 	}
@@ -159,7 +214,7 @@ func (k *KoinosRPC) ApplyTransaction(ctx context.Context, trx *protocol.Transact
 	case *chain.ChainResponse_SubmitTransaction:
 		response = t.SubmitTransaction
 	case *chain.ChainResponse_Error:
-		err = fmt.Errorf("%w ApplyTransaction, chain rpc error, %s", p2perrors.ErrLocalRPC, string(t.Error.GetMessage()))
+		err = fmt.Errorf("%w ApplyTransaction, chain rpc error, %s", classifyTransactionError(t.Error), string(t.Error.GetMessage()))
 	default:
 		err = fmt.Errorf("%w ApplyTransaction, unexpected chain rpc response", p2perrors.ErrLocalRPC)
 	}
@@ -167,6 +222,58 @@ func (k *KoinosRPC) ApplyTransaction(ctx context.Context, trx *protocol.Transact
 	return response, err
 }
 
+// ApplyTransactions submits a batch of transactions, returning the error of each transaction in order.
+// Transactions from the same payer are applied sequentially and in order, since a payer's nonces must
+// be applied in sequence. Different payers are applied concurrently, up to maxConcurrentPayers at a time.
+func (k *KoinosRPC) ApplyTransactions(ctx context.Context, trxs []*protocol.Transaction) []error {
+	errs := make([]error, len(trxs))
+
+	// Group the indices of the transactions by payer, preserving their order
+	var payers []string
+	indices := make(map[string][]int)
+	for i, trx := range trxs {
+		payer := transactionPayer(trx)
+		if _, ok := indices[payer]; !ok {
+			payers = append(payers, payer)
+		}
+		indices[payer] = append(indices[payer], i)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentPayers)
+	for _, payer := range payers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(payerIndices []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, i := range payerIndices {
+				_, errs[i] = k.ApplyTransaction(ctx, trxs[i])
+			}
+		}(indices[payer])
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// transactionPayer returns the compressed public key of the transaction signer, who pays for the transaction.
+// Transactions whose signer cannot be recovered return an empty payer, and are applied in order together.
+func transactionPayer(trx *protocol.Transaction) string {
+	id, err := multihash.Decode(trx.Id)
+	if err != nil {
+		return ""
+	}
+
+	key, _, err := btcec.RecoverCompact(btcec.S256(), trx.SignatureData, id.Digest)
+	if err != nil {
+		return ""
+	}
+
+	return string(key.SerializeCompressed())
+}
+
 // GetBlocksByID rpc call
 func (k *KoinosRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (*block_store.GetBlocksByIdResponse, error) {
 	var idBytes = make([][]byte, len(blockIDs))
@@ -350,6 +457,50 @@ func (k *KoinosRPC) GetForkHeads(ctx context.Context) (*chain.GetForkHeadsRespon
 	return response, err
 }
 
+// GetPendingTransactions rpc call
+func (k *KoinosRPC) GetPendingTransactions(ctx context.Context, limit uint64) (*mempool.GetPendingTransactionsResponse, error) {
+	args := &mempool.MempoolRequest{
+		Request: &mempool.MempoolRequest_GetPendingTransactions{
+			GetPendingTransactions: &mempool.GetPendingTransactionsRequest{
+				Limit: limit,
+			},
+		},
+	}
+
+	data, err := proto.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("%w GetPendingTransactions, %s", p2perrors.ErrSerialization, err)
+	}
+
+	var responseBytes []byte
+	responseBytes, err = k.mq.RPCContext(ctx, "application/octet-stream", MempoolRPC, data)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w GetPendingTransactions, %s", p2perrors.ErrLocalRPCTimeout, err)
+		}
+		return nil, fmt.Errorf("%w GetPendingTransactions, %s", p2perrors.ErrLocalRPC, err)
+	}
+
+	responseVariant := &mempool.MempoolResponse{}
+	err = proto.Unmarshal(responseBytes, responseVariant)
+	if err != nil {
+		return nil, fmt.Errorf("%w GetPendingTransactions, %s", p2perrors.ErrDeserialization, err)
+	}
+
+	var response *mempool.GetPendingTransactionsResponse
+
+	switch t := responseVariant.Response.(type) {
+	case *mempool.MempoolResponse_GetPendingTransactions:
+		response = t.GetPendingTransactions
+	case *mempool.MempoolResponse_Error:
+		err = fmt.Errorf("%w GetPendingTransactions, mempool rpc error, %s", p2perrors.ErrLocalRPC, string(t.Error.GetMessage()))
+	default:
+		err = fmt.Errorf("%w GetPendingTransactions, unexpected mempool rpc response", p2perrors.ErrLocalRPC)
+	}
+
+	return response, err
+}
+
 // IsConnectedToBlockStore returns if the AMQP connection can currently communicate
 // with the block store microservice.
 func (k *KoinosRPC) IsConnectedToBlockStore(ctx context.Context) (bool, error) {
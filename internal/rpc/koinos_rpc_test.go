@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/koinos/koinos-p2p/internal/p2perrors"
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc"
+	"github.com/multiformats/go-multihash"
+)
+
+func TestClassifyBlockError(t *testing.T) {
+	tests := []struct {
+		message string
+		err     error
+	}{
+		{"Unknown previous block", p2perrors.ErrUnknownPreviousBlock},
+		{"previous block does not exist", p2perrors.ErrUnknownPreviousBlock},
+		{"block is unlinkable", p2perrors.ErrUnknownPreviousBlock},
+		{"Duplicate block", p2perrors.ErrDuplicateBlock},
+		{"block already exists", p2perrors.ErrDuplicateBlock},
+		{"block was already applied", p2perrors.ErrDuplicateBlock},
+		{"block exceeds resource limits", p2perrors.ErrBlockResourceLimit},
+		{"insufficient mana", p2perrors.ErrBlockResourceLimit},
+		{"compute bandwidth limit exceeded", p2perrors.ErrBlockResourceLimit},
+		{"invalid transaction", p2perrors.ErrInvalidBlockTransaction},
+		{"invalid nonce", p2perrors.ErrInvalidBlockTransaction},
+		{"invalid block signature", p2perrors.ErrInvalidBlockSignature},
+		{"unexpected signer", p2perrors.ErrInvalidBlockSignature},
+		{"insufficient signatures", p2perrors.ErrInvalidBlockSignature},
+		{"invalid transaction signature", p2perrors.ErrInvalidBlockSignature},
+		{"transaction exceeded the mana limit", p2perrors.ErrBlockResourceLimit},
+		{"insufficient mana for transaction", p2perrors.ErrBlockResourceLimit},
+		{"transaction reverted", p2perrors.ErrBlockApplication},
+		{"duplicate transaction", p2perrors.ErrBlockApplication},
+		{"something else went wrong", p2perrors.ErrBlockApplication},
+		{"", p2perrors.ErrBlockApplication},
+	}
+
+	for _, test := range tests {
+		err := classifyBlockError(&rpc.ErrorResponse{Message: test.message})
+		if err != test.err {
+			t.Errorf("Message %q classified as %v, expected %v", test.message, err, test.err)
+		}
+
+		if !errors.Is(err, p2perrors.ErrBlockApplication) {
+			t.Errorf("Message %q classified as %v, which is not a block application error", test.message, err)
+		}
+	}
+}
+
+func TestClassifyTransactionError(t *testing.T) {
+	tests := []struct {
+		message string
+		err     error
+	}{
+		{"Duplicate transaction", p2perrors.ErrDuplicateTransaction},
+		{"transaction already exists", p2perrors.ErrDuplicateTransaction},
+		{"transaction is already in mempool", p2perrors.ErrDuplicateTransaction},
+		{"transaction was already applied", p2perrors.ErrDuplicateTransaction},
+		{"invalid transaction signature", p2perrors.ErrLocalRPC},
+		{"insufficient mana for transaction", p2perrors.ErrLocalRPC},
+		{"", p2perrors.ErrLocalRPC},
+	}
+
+	for _, test := range tests {
+		err := classifyTransactionError(&rpc.ErrorResponse{Message: test.message})
+		if err != test.err {
+			t.Errorf("Message %q classified as %v, expected %v", test.message, err, test.err)
+		}
+	}
+}
+
+func signedTransaction(t *testing.T, key *btcec.PrivateKey, active string) *protocol.Transaction {
+	id, err := multihash.Sum([]byte(active), multihash.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := multihash.Decode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := btcec.SignCompact(btcec.S256(), key, decoded.Digest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &protocol.Transaction{Id: id, Active: []byte(active), SignatureData: signature}
+}
+
+func TestTransactionPayer(t *testing.T) {
+	key1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payer := transactionPayer(signedTransaction(t, key1, "trx1"))
+	if payer != string(key1.PubKey().SerializeCompressed()) {
+		t.Errorf("Incorrect payer recovered")
+	}
+
+	if transactionPayer(signedTransaction(t, key1, "trx2")) != payer {
+		t.Errorf("Expected transactions signed by the same key to have the same payer")
+	}
+
+	if transactionPayer(signedTransaction(t, key2, "trx3")) == payer {
+		t.Errorf("Expected transactions signed by different keys to have different payers")
+	}
+
+	if transactionPayer(&protocol.Transaction{Id: []byte("invalid")}) != "" {
+		t.Errorf("Expected an unsigned transaction to have no payer")
+	}
+}
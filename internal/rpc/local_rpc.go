@@ -6,6 +6,7 @@ import (
 	"github.com/koinos/koinos-proto-golang/koinos/protocol"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
 	"github.com/koinos/koinos-proto-golang/koinos/rpc/chain"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/mempool"
 	"github.com/multiformats/go-multihash"
 )
 
@@ -14,10 +15,12 @@ type LocalRPC interface {
 	GetHeadBlock(ctx context.Context) (*chain.GetHeadInfoResponse, error)
 	ApplyBlock(ctx context.Context, block *protocol.Block) (*chain.SubmitBlockResponse, error)
 	ApplyTransaction(ctx context.Context, block *protocol.Transaction) (*chain.SubmitTransactionResponse, error)
+	ApplyTransactions(ctx context.Context, transactions []*protocol.Transaction) []error
 	GetBlocksByHeight(ctx context.Context, blockIDs multihash.Multihash, height uint64, numBlocks uint32) (*block_store.GetBlocksByHeightResponse, error)
 	GetChainID(ctx context.Context) (*chain.GetChainIdResponse, error)
 	GetForkHeads(ctx context.Context) (*chain.GetForkHeadsResponse, error)
 	GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (*block_store.GetBlocksByIdResponse, error)
+	GetPendingTransactions(ctx context.Context, limit uint64) (*mempool.GetPendingTransactionsResponse, error)
 
 	IsConnectedToBlockStore(ctx context.Context) (bool, error)
 	IsConnectedToChain(ctx context.Context) (bool, error)
@@ -100,3 +100,107 @@ func (p *PeerRPC) GetBlocks(ctx context.Context, headBlockID multihash.Multihash
 
 	return blocks, nil
 }
+
+// GetBlocksByID rpc call
+func (p *PeerRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (blocks []*protocol.Block, err error) {
+	rpcReq := &GetBlocksByIDRequest{
+		BlockIDs: blockIDs,
+	}
+	rpcResp := &GetBlocksByIDResponse{}
+	err = p.client.CallContext(ctx, p.peerID, "PeerRPCService", "GetBlocksByID", rpcReq, rpcResp)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w, %s", p2perrors.ErrPeerRPCTimeout, err)
+		}
+		return nil, fmt.Errorf("%w, %s", p2perrors.ErrPeerRPC, err)
+	}
+
+	if len(rpcResp.Blocks) > len(blockIDs) {
+		return nil, fmt.Errorf("%w, peer returned unexpected number of blocks", p2perrors.ErrPeerRPC)
+	}
+
+	blocks = make([]*protocol.Block, len(rpcResp.Blocks))
+
+	for i, blockBytes := range rpcResp.Blocks {
+		blocks[i] = &protocol.Block{}
+		err = proto.Unmarshal(blockBytes, blocks[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s", p2perrors.ErrDeserialization, err)
+		}
+	}
+
+	return blocks, nil
+}
+
+// GetPendingTransactions rpc call
+func (p *PeerRPC) GetPendingTransactions(ctx context.Context, start uint64, limit uint64, maxBytes uint64) (transactions []*protocol.Transaction, more bool, err error) {
+	rpcReq := &GetPendingTransactionsRequest{
+		Start:    start,
+		Limit:    limit,
+		MaxBytes: maxBytes,
+	}
+	rpcResp := &GetPendingTransactionsResponse{}
+	err = p.client.CallContext(ctx, p.peerID, "PeerRPCService", "GetPendingTransactions", rpcReq, rpcResp)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, false, fmt.Errorf("%w, %s", p2perrors.ErrPeerRPCTimeout, err)
+		}
+		return nil, false, fmt.Errorf("%w, %s", p2perrors.ErrPeerRPC, err)
+	}
+
+	if uint64(len(rpcResp.Transactions)) > limit {
+		return nil, false, fmt.Errorf("%w, peer returned unexpected number of transactions", p2perrors.ErrPeerRPC)
+	}
+
+	transactions = make([]*protocol.Transaction, len(rpcResp.Transactions))
+
+	for i, trxBytes := range rpcResp.Transactions {
+		transactions[i] = &protocol.Transaction{}
+		err = proto.Unmarshal(trxBytes, transactions[i])
+		if err != nil {
+			return nil, false, fmt.Errorf("%w, %s", p2perrors.ErrDeserialization, err)
+		}
+	}
+
+	return transactions, rpcResp.More, nil
+}
+
+// SubmitBlock rpc call
+func (p *PeerRPC) SubmitBlock(ctx context.Context, block *protocol.Block) error {
+	blockBytes, err := proto.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("%w, %s", p2perrors.ErrSerialization, err)
+	}
+
+	rpcReq := &SubmitBlockRequest{Block: blockBytes}
+	rpcResp := &SubmitBlockResponse{}
+	err = p.client.CallContext(ctx, p.peerID, "PeerRPCService", "SubmitBlock", rpcReq, rpcResp)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w, %s", p2perrors.ErrPeerRPCTimeout, err)
+		}
+		return fmt.Errorf("%w, %s", p2perrors.ErrPeerRPC, err)
+	}
+
+	return nil
+}
+
+// SubmitTransaction rpc call
+func (p *PeerRPC) SubmitTransaction(ctx context.Context, transaction *protocol.Transaction) error {
+	trxBytes, err := proto.Marshal(transaction)
+	if err != nil {
+		return fmt.Errorf("%w, %s", p2perrors.ErrSerialization, err)
+	}
+
+	rpcReq := &SubmitTransactionRequest{Transaction: trxBytes}
+	rpcResp := &SubmitTransactionResponse{}
+	err = p.client.CallContext(ctx, p.peerID, "PeerRPCService", "SubmitTransaction", rpcReq, rpcResp)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w, %s", p2perrors.ErrPeerRPCTimeout, err)
+		}
+		return fmt.Errorf("%w, %s", p2perrors.ErrPeerRPC, err)
+	}
+
+	return nil
+}
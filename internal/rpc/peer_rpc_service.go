@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 
+	"github.com/libp2p/go-libp2p-core/peer"
+	gorpc "github.com/libp2p/go-libp2p-gorpc"
 	"github.com/multiformats/go-multihash"
 	"google.golang.org/protobuf/proto"
 )
@@ -11,6 +13,22 @@ import (
 // PeerRPCID Identifies the peer rpc service
 const PeerRPCID = "/koinos/peerrpc/1.0.0"
 
+// MaxPendingTransactionsLimit is the most transactions a peer may request in a single page
+const MaxPendingTransactionsLimit = 1000
+
+// MaxPendingTransactionsStart is the furthest into the mempool a peer may request a page from, so that
+// a request never fetches more than MaxPendingTransactionsStart+MaxPendingTransactionsLimit transactions
+const MaxPendingTransactionsStart = 10000
+
+// MaxPendingTransactionsBytes is the most bytes of transactions returned in a single page
+const MaxPendingTransactionsBytes = 1024 * 1024
+
+// MaxBlocksByIDLimit is the most blocks a peer may request by id at once
+const MaxBlocksByIDLimit = 100
+
+// MaxBlocksLimit is the most blocks a peer may request by height at once
+const MaxBlocksLimit = 10000
+
 // GetChainIDRequest args
 type GetChainIDRequest struct {
 }
@@ -53,15 +71,65 @@ type GetBlocksResponse struct {
 	Blocks [][]byte
 }
 
+// GetBlocksByIDRequest args
+type GetBlocksByIDRequest struct {
+	BlockIDs []multihash.Multihash
+}
+
+// GetBlocksByIDResponse return
+type GetBlocksByIDResponse struct {
+	Blocks [][]byte
+}
+
+// GetPendingTransactionsRequest args
+type GetPendingTransactionsRequest struct {
+	Start    uint64
+	Limit    uint64
+	MaxBytes uint64
+}
+
+// GetPendingTransactionsResponse return
+type GetPendingTransactionsResponse struct {
+	Transactions [][]byte
+	More         bool
+}
+
+// SubmitBlockRequest args
+type SubmitBlockRequest struct {
+	Block []byte
+}
+
+// SubmitBlockResponse return
+type SubmitBlockResponse struct {
+}
+
+// SubmitTransactionRequest args
+type SubmitTransactionRequest struct {
+	Transaction []byte
+}
+
+// SubmitTransactionResponse return
+type SubmitTransactionResponse struct {
+}
+
+// SubmissionHandler applies the serialized blocks and transactions that peers submit directly,
+// holding the submitting peer accountable for them
+type SubmissionHandler interface {
+	SubmitBlock(ctx context.Context, id peer.ID, data []byte) error
+	SubmitTransaction(ctx context.Context, id peer.ID, data []byte) error
+}
+
 // PeerRPCService implements a libp2p_rpc service
 type PeerRPCService struct {
-	local LocalRPC
+	local       LocalRPC
+	submissions SubmissionHandler
 }
 
 // NewPeerRPCService creates a PeerRPCService
-func NewPeerRPCService(local LocalRPC) *PeerRPCService {
+func NewPeerRPCService(local LocalRPC, submissions SubmissionHandler) *PeerRPCService {
 	return &PeerRPCService{
-		local: local,
+		local:       local,
+		submissions: submissions,
 	}
 }
 
@@ -105,6 +173,10 @@ func (p *PeerRPCService) GetAncestorBlockID(ctx context.Context, request *GetAnc
 
 // GetBlocks peer rpc implementation
 func (p *PeerRPCService) GetBlocks(ctx context.Context, request *GetBlocksRequest, response *GetBlocksResponse) error {
+	if request.NumBlocks > MaxBlocksLimit {
+		return errors.New("too many blocks requested")
+	}
+
 	rpcResult, err := p.local.GetBlocksByHeight(ctx, request.HeadBlockID, request.StartBlockHeight, request.NumBlocks)
 	if err != nil {
 		return err
@@ -120,3 +192,95 @@ func (p *PeerRPCService) GetBlocks(ctx context.Context, request *GetBlocksReques
 
 	return nil
 }
+
+// GetBlocksByID peer rpc implementation
+func (p *PeerRPCService) GetBlocksByID(ctx context.Context, request *GetBlocksByIDRequest, response *GetBlocksByIDResponse) error {
+	if len(request.BlockIDs) > MaxBlocksByIDLimit {
+		return errors.New("too many block ids requested")
+	}
+
+	rpcResult, err := p.local.GetBlocksByID(ctx, request.BlockIDs)
+	if err != nil {
+		return err
+	}
+
+	response.Blocks = make([][]byte, 0, len(rpcResult.BlockItems))
+	for _, block := range rpcResult.BlockItems {
+		// Blocks unknown to the block store are omitted
+		if block.Block == nil {
+			continue
+		}
+
+		blockBytes, err := proto.Marshal(block.Block)
+		if err != nil {
+			return err
+		}
+		response.Blocks = append(response.Blocks, blockBytes)
+	}
+
+	return nil
+}
+
+// GetPendingTransactions peer rpc implementation
+func (p *PeerRPCService) GetPendingTransactions(ctx context.Context, request *GetPendingTransactionsRequest, response *GetPendingTransactionsResponse) error {
+	limit := request.Limit
+	if limit > MaxPendingTransactionsLimit {
+		limit = MaxPendingTransactionsLimit
+	}
+
+	if request.Start > MaxPendingTransactionsStart {
+		return errors.New("pending transactions start out of range")
+	}
+
+	maxBytes := request.MaxBytes
+	if maxBytes == 0 || maxBytes > MaxPendingTransactionsBytes {
+		maxBytes = MaxPendingTransactionsBytes
+	}
+
+	rpcResult, err := p.local.GetPendingTransactions(ctx, request.Start+limit)
+	if err != nil {
+		return err
+	}
+
+	response.Transactions = make([][]byte, 0, limit)
+	response.More = uint64(len(rpcResult.Transactions)) >= request.Start+limit
+
+	totalBytes := uint64(0)
+	for i := request.Start; i < uint64(len(rpcResult.Transactions)); i++ {
+		trxBytes, err := proto.Marshal(rpcResult.Transactions[i])
+		if err != nil {
+			return err
+		}
+
+		// Always return at least one transaction so paging can make progress
+		if len(response.Transactions) > 0 && totalBytes+uint64(len(trxBytes)) > maxBytes {
+			response.More = true
+			break
+		}
+
+		totalBytes += uint64(len(trxBytes))
+		response.Transactions = append(response.Transactions, trxBytes)
+	}
+
+	return nil
+}
+
+// SubmitBlock peer rpc implementation
+func (p *PeerRPCService) SubmitBlock(ctx context.Context, request *SubmitBlockRequest, response *SubmitBlockResponse) error {
+	sender, err := gorpc.GetRequestSender(ctx)
+	if err != nil {
+		return err
+	}
+
+	return p.submissions.SubmitBlock(ctx, sender, request.Block)
+}
+
+// SubmitTransaction peer rpc implementation
+func (p *PeerRPCService) SubmitTransaction(ctx context.Context, request *SubmitTransactionRequest, response *SubmitTransactionResponse) error {
+	sender, err := gorpc.GetRequestSender(ctx)
+	if err != nil {
+		return err
+	}
+
+	return p.submissions.SubmitTransaction(ctx, sender, request.Transaction)
+}
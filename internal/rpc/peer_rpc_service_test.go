@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/koinos/koinos-proto-golang/koinos/protocol"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/block_store"
+	"github.com/koinos/koinos-proto-golang/koinos/rpc/mempool"
+	"github.com/multiformats/go-multihash"
+	"google.golang.org/protobuf/proto"
+)
+
+type testLocalRPC struct {
+	LocalRPC
+	pending        []*protocol.Transaction
+	pendingFetched int
+	blocks         map[string]*protocol.Block
+}
+
+func (t *testLocalRPC) GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (*block_store.GetBlocksByIdResponse, error) {
+	resp := &block_store.GetBlocksByIdResponse{}
+	for _, id := range blockIDs {
+		resp.BlockItems = append(resp.BlockItems, &block_store.BlockItem{BlockId: id, Block: t.blocks[string(id)]})
+	}
+	return resp, nil
+}
+
+func (t *testLocalRPC) GetBlocksByHeight(ctx context.Context, blockID multihash.Multihash, height uint64, numBlocks uint32) (*block_store.GetBlocksByHeightResponse, error) {
+	resp := &block_store.GetBlocksByHeightResponse{}
+	for i := uint32(0); i < numBlocks; i++ {
+		resp.BlockItems = append(resp.BlockItems, &block_store.BlockItem{Block: &protocol.Block{Header: &protocol.BlockHeader{Height: height + uint64(i)}}})
+	}
+	return resp, nil
+}
+
+func (t *testLocalRPC) GetPendingTransactions(ctx context.Context, limit uint64) (*mempool.GetPendingTransactionsResponse, error) {
+	t.pendingFetched++
+	resp := &mempool.GetPendingTransactionsResponse{}
+	for i := 0; i < len(t.pending) && uint64(i) < limit; i++ {
+		resp.Transactions = append(resp.Transactions, t.pending[i])
+	}
+	return resp, nil
+}
+
+func TestGetPendingTransactionsLimits(t *testing.T) {
+	local := &testLocalRPC{}
+	for i := uint64(0); i < 10; i++ {
+		id, _ := multihash.Encode(make([]byte, 1024), i)
+		local.pending = append(local.pending, &protocol.Transaction{Id: id})
+	}
+
+	service := NewPeerRPCService(local, nil)
+
+	// A start beyond the maximum is rejected without fetching the mempool, including one that overflows with the limit
+	for _, start := range []uint64{MaxPendingTransactionsStart + 1, math.MaxUint64 - 10} {
+		response := &GetPendingTransactionsResponse{}
+		err := service.GetPendingTransactions(context.Background(), &GetPendingTransactionsRequest{Start: start, Limit: 100}, response)
+		if err == nil {
+			t.Errorf("Expected start %v to be rejected", start)
+		}
+	}
+
+	if local.pendingFetched != 0 {
+		t.Errorf("Expected no pending transactions fetched for rejected starts, was %v", local.pendingFetched)
+	}
+
+	// A limit above the maximum is clamped
+	response := &GetPendingTransactionsResponse{}
+	err := service.GetPendingTransactions(context.Background(), &GetPendingTransactionsRequest{Start: 2, Limit: math.MaxUint64}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Transactions) != 8 || response.More {
+		t.Errorf("Expected the last 8 transactions without more, was %v more %v", len(response.Transactions), response.More)
+	}
+
+	// Max bytes limits the page, but at least one transaction is returned
+	response = &GetPendingTransactionsResponse{}
+	err = service.GetPendingTransactions(context.Background(), &GetPendingTransactionsRequest{Limit: 10, MaxBytes: 1}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Transactions) != 1 || !response.More {
+		t.Errorf("Expected 1 transaction with more, was %v more %v", len(response.Transactions), response.More)
+	}
+}
+
+func TestGetBlocksLimit(t *testing.T) {
+	service := NewPeerRPCService(&testLocalRPC{}, nil)
+
+	// Requesting more than the limit is rejected
+	response := &GetBlocksResponse{}
+	err := service.GetBlocks(context.Background(), &GetBlocksRequest{StartBlockHeight: 1, NumBlocks: MaxBlocksLimit + 1}, response)
+	if err == nil {
+		t.Errorf("Expected more than %v blocks to be rejected", MaxBlocksLimit)
+	}
+
+	// Requesting exactly the limit succeeds
+	response = &GetBlocksResponse{}
+	err = service.GetBlocks(context.Background(), &GetBlocksRequest{StartBlockHeight: 1, NumBlocks: MaxBlocksLimit}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Blocks) != MaxBlocksLimit {
+		t.Errorf("Expected %v blocks, was %v", MaxBlocksLimit, len(response.Blocks))
+	}
+}
+
+func TestGetBlocksByID(t *testing.T) {
+	local := &testLocalRPC{blocks: make(map[string]*protocol.Block)}
+	ids := make([]multihash.Multihash, 0)
+	for i := uint64(0); i < MaxBlocksByIDLimit+1; i++ {
+		id, _ := multihash.Encode(make([]byte, 32), i)
+		ids = append(ids, id)
+		if i%2 == 0 {
+			local.blocks[string(id)] = &protocol.Block{Id: id}
+		}
+	}
+
+	service := NewPeerRPCService(local, nil)
+
+	// Requesting more than the limit is rejected
+	response := &GetBlocksByIDResponse{}
+	err := service.GetBlocksByID(context.Background(), &GetBlocksByIDRequest{BlockIDs: ids}, response)
+	if err == nil {
+		t.Errorf("Expected more than %v block ids to be rejected", MaxBlocksByIDLimit)
+	}
+
+	// Requesting exactly the limit succeeds, omitting unknown blocks
+	response = &GetBlocksByIDResponse{}
+	err = service.GetBlocksByID(context.Background(), &GetBlocksByIDRequest{BlockIDs: ids[:MaxBlocksByIDLimit]}, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Blocks) != MaxBlocksByIDLimit/2 {
+		t.Fatalf("Expected %v blocks, was %v", MaxBlocksByIDLimit/2, len(response.Blocks))
+	}
+
+	for i, data := range response.Blocks {
+		block := &protocol.Block{}
+		if err = proto.Unmarshal(data, block); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(block.Id, ids[i*2]) {
+			t.Errorf("Block %v is not the requested block", i)
+		}
+	}
+}
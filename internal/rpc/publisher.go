@@ -0,0 +1,6 @@
+package rpc
+
+// Publisher interface for broadcasting messages to other microservices. It is implemented by koinosmq.Client.
+type Publisher interface {
+	Broadcast(contentType string, topic string, args []byte) error
+}
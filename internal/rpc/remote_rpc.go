@@ -13,4 +13,8 @@ type RemoteRPC interface {
 	GetHeadBlock(ctx context.Context) (id multihash.Multihash, height uint64, err error)
 	GetAncestorBlockID(ctx context.Context, parentID multihash.Multihash, childHeight uint64) (id multihash.Multihash, err error)
 	GetBlocks(ctx context.Context, headBlockID multihash.Multihash, startBlockHeight uint64, batchSize uint32) (blocks []protocol.Block, err error)
+	GetBlocksByID(ctx context.Context, blockIDs []multihash.Multihash) (blocks []*protocol.Block, err error)
+	GetPendingTransactions(ctx context.Context, start uint64, limit uint64, maxBytes uint64) (transactions []*protocol.Transaction, more bool, err error)
+	SubmitBlock(ctx context.Context, block *protocol.Block) error
+	SubmitTransaction(ctx context.Context, transaction *protocol.Transaction) error
 }
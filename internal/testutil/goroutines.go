@@ -0,0 +1,40 @@
+// Package testutil provides helpers shared by tests of several packages
+package testutil
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// GoroutineCount counts running goroutines, excluding the address books of the AutoNAT
+// dialers which libp2p never closes
+func GoroutineCount() int {
+	buf := make([]byte, 1<<22)
+	stacks := strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n")
+
+	count := 0
+	for _, stack := range stacks {
+		if !strings.Contains(stack, "pstoremem.(*memoryAddrBook).background") {
+			count++
+		}
+	}
+
+	return count
+}
+
+// WaitForGoroutines waits for the number of goroutines to return to at most the given count
+func WaitForGoroutines(t *testing.T, count int) {
+	t.Helper()
+
+	// Allow time for NAT discovery started by libp2p to time out
+	deadline := time.Now().Add(time.Second * 10)
+	for GoroutineCount() > count {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<22)
+			t.Fatalf("Goroutines leaked. Expected at most %v, was %v\n%s", count, GoroutineCount(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+}
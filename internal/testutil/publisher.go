@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"sync"
+)
+
+// TestPublisher stands in for the message queue client, recording broadcasts
+type TestPublisher struct {
+	ContentTypes []string
+	Topics       []string
+	Messages     [][]byte
+	Mutex        sync.Mutex
+}
+
+// Broadcast records the message
+func (p *TestPublisher) Broadcast(contentType string, topic string, args []byte) error {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	p.ContentTypes = append(p.ContentTypes, contentType)
+	p.Topics = append(p.Topics, topic)
+	p.Messages = append(p.Messages, args)
+	return nil
+}
+
+// Find returns the first message broadcast on the topic, or nil
+func (p *TestPublisher) Find(topic string) []byte {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	for i, t := range p.Topics {
+		if t == topic {
+			return p.Messages[i]
+		}
+	}
+
+	return nil
+}